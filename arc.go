@@ -0,0 +1,119 @@
+package mem
+
+// arcPolicy is the Adaptive Replacement Cache policy, it balances between the
+// recently used keys (t1) and the frequently used keys (t2) by remembering the
+// keys it recently evicted from each of them (b1 and b2)
+type arcPolicy struct {
+	capacity int      // target number of entries, 0 follows the number of stored entries
+	p        int      // target size of t1
+	t1       *keyList // keys used once recently
+	t2       *keyList // keys used at least twice recently
+	b1       *keyList // keys recently evicted from t1
+	b2       *keyList // keys recently evicted from t2
+}
+
+// NewARCPolicy returns an adaptive replacement cache eviction policy
+func NewARCPolicy(capacity int) EvictionPolicy {
+	return &arcPolicy{
+		capacity: capacity,
+		t1:       newKeyList(),
+		t2:       newKeyList(),
+		b1:       newKeyList(),
+		b2:       newKeyList(),
+	}
+}
+
+// size returns the capacity used to bound p and the ghost lists
+func (p *arcPolicy) size() int {
+	if p.capacity > 0 {
+		return p.capacity
+	}
+
+	// A byte budget cache has no fixed capacity, use the stored entries instead
+	if n := p.t1.len() + p.t2.len(); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// OnInsert stores a new key in t1, or in t2 if it was evicted recently
+func (p *arcPolicy) OnInsert(key string) {
+	if p.t1.has(key) || p.t2.has(key) {
+		p.OnAccess(key)
+		return
+	}
+
+	c := p.size()
+	switch {
+	case p.b1.has(key):
+		// Recency was evicted too early, grow t1
+		p.p = minInt(c, p.p+maxInt(p.b2.len()/p.b1.len(), 1))
+		p.b1.remove(key)
+		p.t2.pushFront(key)
+
+	case p.b2.has(key):
+		// Frequency was evicted too early, shrink t1
+		p.p = maxInt(0, p.p-maxInt(p.b1.len()/p.b2.len(), 1))
+		p.b2.remove(key)
+		p.t2.pushFront(key)
+
+	default:
+		p.t1.pushFront(key)
+	}
+
+	// Keep the ghost lists bounded by the capacity
+	for p.b1.len() > 0 && p.t1.len()+p.b1.len() > c {
+		p.b1.removeBack()
+	}
+	for p.b2.len() > 0 && p.t1.len()+p.t2.len()+p.b1.len()+p.b2.len() > 2*c {
+		p.b2.removeBack()
+	}
+}
+
+// OnAccess promotes the key to the most recently used of t2
+func (p *arcPolicy) OnAccess(key string) {
+	switch {
+	case p.t1.has(key):
+		p.t1.remove(key)
+		p.t2.pushFront(key)
+	case p.t2.has(key):
+		p.t2.moveToFront(key)
+	}
+}
+
+// OnRemove forgets a stored key, the ghost lists are kept
+func (p *arcPolicy) OnRemove(key string) {
+	p.t1.remove(key)
+	p.t2.remove(key)
+}
+
+// Victim moves the least recently used key of t1 or t2 to its ghost list and returns it
+func (p *arcPolicy) Victim() (string, bool) {
+	if p.t1.len() > 0 && (p.t1.len() > p.p || p.t2.len() == 0) {
+		key := p.t1.removeBack()
+		p.b1.pushFront(key)
+		return key, true
+	}
+	if p.t2.len() > 0 {
+		key := p.t2.removeBack()
+		p.b2.pushFront(key)
+		return key, true
+	}
+	return "", false
+}
+
+// minInt returns the smaller of a and b
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of a and b
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package mem
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ConflictError is returned by CompareAndSwap when the data changed since the expected version was read
+type ConflictError struct {
+	Key      string // key of the data
+	Expected uint64 // version the caller expected
+	Actual   uint64 // current version of the data
+}
+
+// Error returns the error message
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s, expected version %d, actual version %d", ErrConflict, e.Key, e.Expected, e.Actual)
+}
+
+// Is returns true for ErrConflict
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// GetWithVersion gets the data and its version from the cache
+func (c *Cache) GetWithVersion(key string) ([]byte, uint64, bool) {
+	return c.lookup(key)
+}
+
+// CompareAndSwap replaces the value of the data only if its version is still the
+// expected one, the expiry and the tags are kept. It returns the new version, or a ConflictError
+// if the data was written in between.
+func (c *Cache) CompareAndSwap(key string, expected uint64, value []byte) (uint64, error) {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.data[key]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if data.isExpiredAt(c.now().UnixNano()) {
+		return 0, fmt.Errorf("%w: %s", ErrExpired, key)
+	}
+	if data.Version != expected {
+		return 0, &ConflictError{Key: key, Expected: expected, Actual: data.Version}
+	}
+
+	m := &MemData{Value: value, Tags: data.Tags}
+	m.copyExpiry(data)
+	if err := c.replaceLocked(s, data, m, &evicted); err != nil {
+		return 0, err
+	}
+	atomic.AddUint64(&c.counters.replaces, 1)
+	return data.Version, nil
+}
+
+// GetWithVersion gets the data and its version from the default cache
+func GetWithVersion(key string) ([]byte, uint64, bool) {
+	return defaultCache.GetWithVersion(key)
+}
+
+// SetWithVersion sets the data in the default cache and returns its version
+func SetWithVersion(m *MemData) (uint64, error) {
+	return defaultCache.SetWithVersion(m)
+}
+
+// CompareAndSwap replaces the value of the data in the default cache if its version is the expected one
+func CompareAndSwap(key string, expected uint64, value []byte) (uint64, error) {
+	return defaultCache.CompareAndSwap(key, expected, value)
+}
@@ -0,0 +1,78 @@
+package mem
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	c := NewCache()
+
+	v1, err := c.SetWithVersion(&MemData{Key: "key", Value: []byte("value"), Expire: time.Now().Add(time.Hour).Unix()})
+	if err != nil || v1 == 0 {
+		t.Fatalf("SetWithVersion: got %d, %v", v1, err)
+	}
+	if _, v, ok := c.GetWithVersion("key"); !ok || v != v1 {
+		t.Errorf("GetWithVersion: got %d, %v, want %d", v, ok, v1)
+	}
+
+	v2, err := c.CompareAndSwap("key", v1, []byte("value2"))
+	if err != nil || v2 <= v1 {
+		t.Fatalf("CompareAndSwap: got %d, %v", v2, err)
+	}
+
+	// The stale version conflicts
+	_, err = c.CompareAndSwap("key", v1, []byte("value3"))
+	var conflict *ConflictError
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) || conflict.Actual != v2 {
+		t.Errorf("CompareAndSwap: got %v, want a conflict at version %d", err, v2)
+	}
+	if v, _ := c.Get("key"); string(v) != "value2" {
+		t.Errorf("Get: got %q, want value2", v)
+	}
+
+	// Every write changes the version, and the expiry is kept by CompareAndSwap
+	c.Replace("key", &MemData{Value: []byte("value4"), Expire: time.Now().Add(time.Hour).Unix()})
+	if _, v, _ := c.GetWithVersion("key"); v <= v2 {
+		t.Errorf("Replace did not change the version: %d", v)
+	}
+	if c.shards[0].data["key"].Expire == 0 {
+		t.Error("expiry was lost")
+	}
+
+	if _, err := c.CompareAndSwap("missing", 1, []byte("value")); err == nil {
+		t.Error("CompareAndSwap of a missing key succeeded")
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	c := NewCache()
+	c.Set(&MemData{Key: "counter", Value: []byte{0}})
+
+	// Every writer retries on conflict, so no update is lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				for {
+					v, version, _ := c.GetWithVersion("counter")
+					if _, err := c.CompareAndSwap("counter", version, []byte{v[0] + 1}); err == nil {
+						break
+					} else if !errors.Is(err, ErrConflict) {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := c.Get("counter"); v[0] != 200 {
+		t.Errorf("counter: got %d, want 200", v[0])
+	}
+}
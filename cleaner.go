@@ -1,6 +1,7 @@
 package mem
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"strconv"
@@ -15,6 +16,7 @@ const (
 	DAILY
 	WEEKLY
 	MONTHLY
+	CRON
 )
 
 // Frequently interval options, avoid 0 as it is the default
@@ -35,12 +37,21 @@ const (
 	SATURDAY
 )
 
+// Misfire policy options, what a late cleaner does about the runs it missed,
+// avoid 0 as it is the default, which is MISFIRE_RUN_ONCE
+const (
+	MISFIRE_RUN_ONCE = iota + 1 // run once for all the missed runs
+	MISFIRE_RUN_ALL             // run once for every missed run
+	MISFIRE_SKIP                // skip the missed runs and wait for the next one
+)
+
 // Common cleaner config options
 const (
 	FREQUENTLY_SCHEDULE_TYPE = "frequently"
 	DAILY_SCHEDULE_TYPE      = "daily"
 	WEEKLY_SCHEDULE_TYPE     = "weekly"
 	MONTHLY_SCHEDULE_TYPE    = "monthly"
+	CRON_SCHEDULE_TYPE       = "cron"
 	FREQUENTLY_EVERY_SECOND  = "EVERY_SECOND"
 	FREQUENTLY_EVERY_MINUTE  = "EVERY_MINUTE"
 	FREQUENTLY_EVERY_HOUR    = "EVERY_HOUR"
@@ -50,10 +61,14 @@ const (
 
 // CleanerSchedule is a struct that holds the data for the cleaner schedule
 type CleanerSchedule struct {
-	ScheduleType  int    // FREQUENTLY, DAILY, WEEKLY, MONTHLY
-	Interval      int    // interval for the schedule
-	IntervalValue int    // interval value for the schedule
-	StartTime     string // input time for the schedule using 24 hour format e.g 23:00
+	ScheduleType  int            // FREQUENTLY, DAILY, WEEKLY, MONTHLY, CRON
+	Interval      int            // interval for the schedule
+	IntervalValue int            // interval value for the schedule
+	StartTime     string         // input time for the schedule using 24 hour format e.g 23:00
+	Misfire       int            // MISFIRE_RUN_ONCE, MISFIRE_RUN_ALL or MISFIRE_SKIP, 0 means MISFIRE_RUN_ONCE
+	CronExpr      string         // cron expression for the CRON schedule e.g. "*/15 9-17 * * MON-FRI"
+	Location      *time.Location // time zone of the start time and the cron expression, nil means time.Local
+	Clock         Clock          // tells the time and makes the timers of the scheduler, nil means the real time
 }
 
 // Cleaner is a struct that holds the data for the cleaner
@@ -64,6 +79,12 @@ type Cleaner struct {
 	NextRun  int64         // unix timestamp of when the next run will be
 	Remarks  string        // last run remarks
 	mu       *sync.RWMutex // read-write mutex, multiple readers, single writer
+
+	LastDuration time.Duration // how long the last run took
+	LastRemoved  int           // number of expired data removed by the last run
+	Runs         int64         // number of runs so far
+	TotalRemoved int64         // number of expired data removed by all the runs
+	Missed       int64         // number of runs missed and not run because of the misfire policy
 }
 
 // CleanerOption is a cleaner option interface
@@ -77,16 +98,22 @@ type CleanerOption interface {
 	Error() error
 }
 
+// scheduleOption is implemented by the options that set the other fields of the
+// schedule, so CleanerOption does not grow with every new option
+type scheduleOption interface {
+	apply(cs *CleanerSchedule)
+}
+
 // Command returns the command to run the cleaner
 type Command interface {
-	Run(h *Cache)
+	Run(h Cleanable)
 	Client() *Cache
 }
 
-// Client returns the mem cache client
+// Client sets the default cache used by the package-level functions and returns it
 func Client(m *Cache) *Cache {
-	c = m
-	return c
+	defaultCache = m
+	return defaultCache
 }
 
 // IntervalOpt returns the interval for the cleaner
@@ -119,6 +146,31 @@ func (c *CleanerSchedule) SetStartTime(startTime string) {
 	c.StartTime = startTime
 }
 
+// apply sets the misfire policy, the cron expression, the time zone and the
+// clock of the schedule that are set in the option
+func (c *CleanerSchedule) apply(cs *CleanerSchedule) {
+	if c.Misfire != 0 {
+		cs.Misfire = c.Misfire
+	}
+	if c.CronExpr != "" {
+		cs.CronExpr = c.CronExpr
+	}
+	if c.Location != nil {
+		cs.Location = c.Location
+	}
+	if c.Clock != nil {
+		cs.Clock = c.Clock
+	}
+}
+
+// location returns the time zone of the schedule, time.Local if none is set
+func (c *CleanerSchedule) location() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
 // Error returns the error for the cleaner
 func (cs *CleanerSchedule) Error() error {
 	// Get the schedule type name
@@ -130,11 +182,16 @@ func (cs *CleanerSchedule) Error() error {
 	// Validates the schedule type
 	isValidScheduleType := false
 	switch cs.ScheduleType {
-	case FREQUENTLY, DAILY, WEEKLY, MONTHLY:
+	case FREQUENTLY, DAILY, WEEKLY, MONTHLY, CRON:
 		isValidScheduleType = true
 	}
 	if !isValidScheduleType {
-		return fmt.Errorf("invalid schedule type: %s", schedTypeName)
+		return fmt.Errorf("%w type: %s", ErrInvalidSchedule, schedTypeName)
+	}
+
+	// The cron expression is only for the cron schedule
+	if cs.ScheduleType != CRON && len(strings.TrimSpace(cs.CronExpr)) != 0 {
+		return fmt.Errorf("%w: cron expression input for the schedule type %s is not allowed", ErrInvalidSchedule, schedTypeName)
 	}
 
 	isValidInterval := false
@@ -147,55 +204,69 @@ func (cs *CleanerSchedule) Error() error {
 			case 0:
 				isValidInterval = true
 			default:
-				return fmt.Errorf("start time input for the schedule type %s is not allowed", schedTypeName)
+				return fmt.Errorf("%w: start time input for the schedule type %s is not allowed", ErrInvalidSchedule, schedTypeName)
 			}
 		default:
-			return fmt.Errorf("invalid interval: %d, options are: %s, %s, %s", cs.Interval,
+			return fmt.Errorf("%w: invalid interval: %d, options are: %s, %s, %s", ErrInvalidSchedule, cs.Interval,
 				FREQUENTLY_EVERY_SECOND, FREQUENTLY_EVERY_MINUTE, FREQUENTLY_EVERY_HOUR)
 		}
 
 	case DAILY:
-		// It will start in the next day at the start time of the day
-		// If no start time is provided, then it's an invalid interval
-		switch len(strings.TrimSpace(cs.StartTime)) {
-		case 0:
-			return fmt.Errorf("invalid start time: %s", cs.StartTime)
-		default:
-			isValidInterval = true
+		// It runs every day at the start time of the day
+		if err := validateStartTime(cs.StartTime); err != nil {
+			return err
 		}
+		isValidInterval = true
 
 	case WEEKLY:
 		switch cs.Interval {
 		case SUNDAY, MONDAY, TUESDAY, WEDNESDAY, THURSDAY, FRIDAY, SATURDAY:
-			// If no start time is provided, then it's an invalid interval
-			switch len(strings.TrimSpace(cs.StartTime)) {
-			case 0:
-				return fmt.Errorf("invalid start time: %s", cs.StartTime)
-			default:
-				isValidInterval = true
+			if err := validateStartTime(cs.StartTime); err != nil {
+				return err
 			}
+			isValidInterval = true
 		}
 
 	case MONTHLY:
-		// If no start time is provided, then it's an invalid interval
-		switch len(strings.TrimSpace(cs.StartTime)) {
-		case 0:
-			return fmt.Errorf("invalid start time: %s", cs.StartTime)
-		default:
-			isValidInterval = true
+		if err := validateStartTime(cs.StartTime); err != nil {
+			return err
 		}
+		isValidInterval = true
 
 		// Day validation from 1-31 days only
 		switch {
 		case cs.Interval >= 1 && cs.Interval <= 31:
 			isValidInterval = true
 		default:
-			return fmt.Errorf("invalid interval: %d, options are: 1-31", cs.Interval)
+			return fmt.Errorf("%w: invalid interval: %d, options are: 1-31", ErrInvalidSchedule, cs.Interval)
+		}
+
+	case CRON:
+		// The cron expression holds the whole schedule
+		if len(strings.TrimSpace(cs.StartTime)) != 0 || cs.Interval != 0 {
+			return fmt.Errorf("%w: interval and start time input for the schedule type %s are not allowed", ErrInvalidSchedule, schedTypeName)
 		}
+		ce, err := parseCronExpr(cs.CronExpr)
+		if err != nil {
+			return fmt.Errorf("%w: invalid cron expression %q: %v", ErrInvalidSchedule, cs.CronExpr, err)
+		}
+
+		// An expression like "0 0 30 2 *" never matches, from any time
+		if ce.next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+			return fmt.Errorf("%w: cron expression %q never runs", ErrInvalidSchedule, cs.CronExpr)
+		}
+		isValidInterval = true
 	}
 
 	if !isValidInterval {
-		return fmt.Errorf("invalid interval: %d for the schedule type: %s", cs.Interval, schedTypeName)
+		return fmt.Errorf("%w: invalid interval: %d for the schedule type: %s", ErrInvalidSchedule, cs.Interval, schedTypeName)
+	}
+
+	// Validates the misfire policy, 0 is the default
+	switch cs.Misfire {
+	case 0, MISFIRE_RUN_ONCE, MISFIRE_RUN_ALL, MISFIRE_SKIP:
+	default:
+		return fmt.Errorf("%w: invalid misfire policy: %d", ErrInvalidSchedule, cs.Misfire)
 	}
 	return nil
 }
@@ -228,6 +299,22 @@ func WithDayOfMonth(dayOfMonth int) CleanerOption {
 	return &CleanerSchedule{Interval: dayOfMonth}
 }
 
+// WithMisfire sets the misfire policy for the cleaner
+func WithMisfire(misfire int) CleanerOption {
+	return &CleanerSchedule{Misfire: misfire}
+}
+
+// WithCronExpr sets the cron expression for the cleaner of the CRON schedule type
+func WithCronExpr(cronExpr string) CleanerOption {
+	return &CleanerSchedule{CronExpr: cronExpr}
+}
+
+// WithLocation sets the time zone of the start time and the cron expression for
+// the cleaner, e.g. to run at 03:00 in the time zone of the users on a server in UTC
+func WithLocation(loc *time.Location) CleanerOption {
+	return &CleanerSchedule{Location: loc}
+}
+
 // NewCleaner creates a new cleaner
 func NewCleaner(scheduleType int, opts ...CleanerOption) (*Cleaner, error) {
 	c := &Cleaner{
@@ -262,6 +349,11 @@ func NewCleaner(scheduleType int, opts ...CleanerOption) (*Cleaner, error) {
 		default:
 			c.Schedule.SetStartTime(opt.StartTimeOpt())
 		}
+
+		// Set the other fields of the schedule
+		if so, ok := opt.(scheduleOption); ok {
+			so.apply(c.Schedule)
+		}
 	}
 
 	// Check for errors
@@ -271,11 +363,52 @@ func NewCleaner(scheduleType int, opts ...CleanerOption) (*Cleaner, error) {
 	return c, nil
 }
 
-// ChannelTS is a channel timestamp
+// ChannelTS is a channel timestamp, sending on it stops one of the cleaners
+// started with Run, use RunContext or Start to stop a given cleaner
 var ChannelTS = make(chan bool, 1)
 
-// Run runs the cleaner
-func (c *Cleaner) Run(h *Cache) {
+// Run runs the cleaner until a value is received from ChannelTS
+func (c *Cleaner) Run(h Cleanable) {
+	c.run(context.Background(), ChannelTS, h)
+}
+
+// RunContext runs the cleaner until the context is done. The expired data is
+// cleaned in the calling goroutine, so when RunContext returns no cleaning is
+// in progress.
+func (c *Cleaner) RunContext(ctx context.Context, h Cleanable) {
+	c.run(ctx, nil, h)
+}
+
+// CleanerHandle controls a cleaner started with Start
+type CleanerHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the cleaner stopped
+}
+
+// Start runs the cleaner in a new goroutine and returns the handle to stop it
+func (c *Cleaner) Start(h Cleanable) *CleanerHandle {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := &CleanerHandle{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(ch.done)
+		c.RunContext(ctx, h)
+	}()
+	return ch
+}
+
+// Stop stops the cleaner and waits for the cleaning in progress to finish
+func (ch *CleanerHandle) Stop() {
+	ch.cancel()
+	<-ch.done
+}
+
+// Wait waits for the cleaner to stop
+func (ch *CleanerHandle) Wait() {
+	<-ch.done
+}
+
+// run runs the cleaner until the context is done or a value is received from stop
+func (c *Cleaner) run(ctx context.Context, stop <-chan bool, h Cleanable) {
 	switch c.Schedule.ScheduleType {
 	case FREQUENTLY:
 		c.CleanFrequently()
@@ -285,84 +418,104 @@ func (c *Cleaner) Run(h *Cache) {
 		c.CleanWeekly()
 	case MONTHLY:
 		c.CleanMonthly()
+	case CRON:
+		c.CleanCron()
 	}
 
-	// Add the cleaner to the list
+	// List the cleaner while it runs
 	c.AddCleaner()
+	defer c.removeCleaner()
 
-	// Itereate over the list of cleaners and run them
+	// Wait for the next run until the cleaner is stopped, the timer fires at
+	// or after the next run and execRunner catches up with the missed runs
+	clk := c.getClock()
 	for {
+		c.mu.RLock()
+		nextRun := time.Unix(c.NextRun, 0)
+		c.mu.RUnlock()
+
+		timer, stopTimer := clk.NewTimer(nextRun.Sub(clk.Now()))
 		select {
-		case <-ChannelTS:
+		case <-ctx.Done():
+			stopTimer()
+			return
+		case <-stop:
+			stopTimer()
 			return
-		case <-time.After(500 * time.Millisecond):
-			go execRunner(c, h)
+		case <-timer:
+			execRunner(c, h)
 		}
 	}
 }
 
-// execRunner is the runner for the exec command
-func execRunner(c *Cleaner, h *Cache) {
-	// Scan the list of cleaners and run them
-	for _, e := range GetAllCleanerSchedules() {
-		for _, s := range e {
-			// Check if due for execution
-			if s.NextRun == time.Now().Local().Unix() {
-				c.UpdateNextRun(s.TaskName) // Update the next run time for the task
-				CleanExpired(h)
-			}
+// execRunner runs the cleaner if it is due, the misfire policy decides how
+// many times it runs when it is late by more than one run
+func execRunner(c *Cleaner, h Cleanable) {
+	clk := c.getClock()
+
+	c.mu.Lock()
+	now := clk.Now()
+	nextRun := time.Unix(c.NextRun, 0)
+	if now.Before(nextRun) {
+		c.mu.Unlock()
+		return
+	}
+
+	// Schedule the next run first, so a slow clean does not delay it
+	due, nextRun := c.Schedule.dueRuns(nextRun, now)
+	c.NextRun = nextRun.Unix()
+	runs := 1
+	switch c.Schedule.Misfire {
+	case MISFIRE_RUN_ALL:
+		runs = due
+	case MISFIRE_SKIP:
+		if due > 1 {
+			runs = 0
 		}
 	}
+	c.Missed += int64(due - runs)
+	UpdateCleaner(c, c.TaskName)
+	c.mu.Unlock()
+
+	for i := 0; i < runs; i++ {
+		start := clk.Now()
+		removed := h.CleanExpired()
+		c.recordRun(start, clk.Now().Sub(start), removed)
+	}
 }
 
-// UpdateNextRun updates the next run time
-func (c *Cleaner) UpdateNextRun(taskName string) {
+// recordRun records the run data of the cleaner
+func (c *Cleaner) recordRun(start time.Time, duration time.Duration, removed int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	switch c.Schedule.ScheduleType {
-	case FREQUENTLY:
-		// Check the interval value
-		if c.Schedule.IntervalValue <= 0 {
-			c.Schedule.IntervalValue = 1
-		}
+	c.LastRun = start.Unix()
+	c.LastDuration = duration
+	c.LastRemoved = removed
+	c.Runs++
+	c.TotalRemoved += int64(removed)
+	c.Remarks = fmt.Sprintf("%s ran successfully on %s", c.TaskName, start.In(c.Schedule.location()).Format(DT_FORMAT))
 
-		switch c.Schedule.Interval {
-		case EVERY_SECOND:
-			c.NextRun = time.Now().Local().Add(time.Second * time.Duration(c.Schedule.IntervalValue)).Unix()
-
-		case EVERY_MINUTE:
-			c.NextRun = time.Now().Local().Add(time.Minute * time.Duration(c.Schedule.IntervalValue)).Unix()
-
-		case EVERY_HOUR:
-			c.NextRun = time.Now().Local().Add(time.Hour * time.Duration(c.Schedule.IntervalValue)).Unix()
-		}
-
-	case DAILY:
-		// Get the start time hour and minute
-		startTimeHour, startTimeMinute := GetTime(c.Schedule.StartTime)
-
-		// Start the cleaner for the following day with start time
-		c.NextRun = time.Date(time.Now().Local().Year(), time.Now().Local().Month(), time.Now().Local().Day()+1, startTimeHour, startTimeMinute, 0, 0, time.Local).Unix()
-
-	case WEEKLY:
-		// Get the start time hour and minute
-		startTimeHour, startTimeMinute := GetTime(c.Schedule.StartTime)
-		dayOfWeek := c.Schedule.Interval - 1
-
-		// Find the next dayOfWeek in the future from the current day
-		nextDayOfWeek := time.Now().Local().AddDate(0, 0, (int(dayOfWeek) + int(time.Now().Local().Weekday()) - 1))
-		c.NextRun = time.Date(nextDayOfWeek.Year(), nextDayOfWeek.Month(), nextDayOfWeek.Day(), startTimeHour, startTimeMinute, 0, 0, time.Local).Unix()
-
-	case MONTHLY:
-		// Get the start time hour and minute
-		startTimeHour, startTimeMinute := GetTime(c.Schedule.StartTime)
+	// Update cleaner TS with the run data
+	UpdateCleaner(c, c.TaskName)
+}
 
-		// Find the next dayOfMonth in the future from the current day
-		c.NextRun = time.Date(time.Now().Local().Year(), time.Now().Local().Month()+1, c.Schedule.Interval, startTimeHour, startTimeMinute, 0, 0, time.Local).Unix()
+// getClock returns the clock of the cleaner, the real clock if none is set
+func (c *Cleaner) getClock() Clock {
+	if c.Schedule.Clock == nil {
+		return realClock{}
 	}
+	return c.Schedule.Clock
+}
+
+// UpdateNextRun updates the next run time
+func (c *Cleaner) UpdateNextRun(taskName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	c.Remarks = fmt.Sprintf("%s ran successfully on %s", taskName, time.Now().Local().Format(DT_FORMAT))
+	now := c.getClock().Now()
+	c.NextRun = c.Schedule.nextRunAfter(now).Unix()
+	c.Remarks = fmt.Sprintf("%s ran successfully on %s", taskName, now.In(c.Schedule.location()).Format(DT_FORMAT))
 
 	// Update cleaner TS with the new next run time
 	UpdateCleaner(c, taskName)
@@ -399,6 +552,17 @@ func (c *Cleaner) AddCleaner() {
 	TS.mu.Unlock()
 }
 
+// removeCleaner removes the cleaner from the list of cleaners
+func (c *Cleaner) removeCleaner() {
+	c.mu.RLock()
+	taskName := c.TaskName
+	c.mu.RUnlock()
+
+	TS.mu.Lock()
+	delete(TS.CleanerList, taskName)
+	TS.mu.Unlock()
+}
+
 // UpdateCleaner update the cleaner with the new one
 func UpdateCleaner(c *Cleaner, taskName string) {
 	TS.mu.Lock()
@@ -413,92 +577,258 @@ func (s *CleanerScheduler) GetCleanerSchedule(key string) (Cleaner, error) {
 
 	// Check if the key is present
 	if _, ok := s.CleanerList[key]; !ok {
-		return Cleaner{}, fmt.Errorf("cleaner not found for key: %s", key)
+		return Cleaner{}, fmt.Errorf("%w for key: %s", ErrCleanerNotFound, key)
 	}
 	return s.CleanerList[key][0], nil
 }
 
-// GetAllCleanerSchedules returns the list of cleaners
+// GetAllCleanerSchedules returns a copy of the list of cleaners
 func GetAllCleanerSchedules() map[string][]Cleaner {
-	TS.mu.Lock()
-	defer TS.mu.Unlock()
-	return TS.CleanerList
+	TS.mu.RLock()
+	defer TS.mu.RUnlock()
+
+	list := make(map[string][]Cleaner, len(TS.CleanerList))
+	for k, v := range TS.CleanerList {
+		list[k] = append([]Cleaner(nil), v...)
+	}
+	return list
 }
 
-// CleanFrequently runs the cleaner frequently
+// CleanFrequently schedules the first run of the frequently cleaner
 func (c *Cleaner) CleanFrequently() {
+	c.scheduleFirstRun()
+}
+
+// CleanDaily schedules the first run of the daily cleaner
+func (c *Cleaner) CleanDaily() {
+	c.scheduleFirstRun()
+}
+
+// CleanWeekly schedules the first run of the weekly cleaner
+func (c *Cleaner) CleanWeekly() {
+	c.scheduleFirstRun()
+}
+
+// CleanMonthly schedules the first run of the monthly cleaner
+func (c *Cleaner) CleanMonthly() {
+	c.scheduleFirstRun()
+}
+
+// CleanCron schedules the first run of the cron cleaner
+func (c *Cleaner) CleanCron() {
+	c.scheduleFirstRun()
+}
+
+// scheduleFirstRun sets the next run to the first run of the schedule from now
+func (c *Cleaner) scheduleFirstRun() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.NextRun = c.Schedule.firstRunAfter(c.getClock().Now()).Unix()
+}
 
-	// Check the interval value
-	if c.Schedule.IntervalValue <= 0 {
-		c.Schedule.IntervalValue = 1
-	}
+// firstRunAfter returns the first run of the schedule started at t. The daily
+// schedule starts on the following day and the monthly schedule in the following
+// month, even if the start time has not passed yet today or this month.
+func (cs *CleanerSchedule) firstRunAfter(t time.Time) time.Time {
+	loc := cs.location()
+	t = t.In(loc)
 
-	switch c.Schedule.Interval {
-	case EVERY_SECOND:
-		c.NextRun = time.Now().Local().Add(time.Second * time.Duration(c.Schedule.IntervalValue)).Unix()
-	case EVERY_MINUTE:
-		c.NextRun = time.Now().Local().Add(time.Minute * time.Duration(c.Schedule.IntervalValue)).Unix()
-	case EVERY_HOUR:
-		c.NextRun = time.Now().Local().Add(time.Hour * time.Duration(c.Schedule.IntervalValue)).Unix()
+	switch cs.ScheduleType {
+	case DAILY:
+		t = wallTime(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, loc).Add(-time.Nanosecond)
+	case MONTHLY:
+		t = wallTime(t.Year(), t.Month()+1, 1, 0, 0, 0, loc).Add(-time.Nanosecond)
 	}
+	return cs.nextRunAfter(t)
 }
 
-// CleanDaily runs the cleaner daily
-func (c *Cleaner) CleanDaily() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// nextRunAfter returns the first run of the schedule strictly after t. The start
+// time and the cron expression are wall clock times of the schedule location,
+// see wallTime for the times the clocks skip or repeat when the DST changes.
+func (cs *CleanerSchedule) nextRunAfter(t time.Time) time.Time {
+	loc := cs.location()
+	t = t.In(loc)
+	startTimeHour, startTimeMinute := GetTime(cs.StartTime)
+
+	switch cs.ScheduleType {
+	case FREQUENTLY:
+		return t.Truncate(time.Second).Add(cs.period())
 
-	// Get the start time hour and minute
-	startTimeHour, startTimeMinute := GetTime(c.Schedule.StartTime)
+	case DAILY:
+		next := wallTime(t.Year(), t.Month(), t.Day(), startTimeHour, startTimeMinute, 0, loc)
+		if !next.After(t) {
+			next = wallTime(t.Year(), t.Month(), t.Day()+1, startTimeHour, startTimeMinute, 0, loc)
+		}
+		return next
+
+	case WEEKLY:
+		// Days until the weekday, SUNDAY is 1 and time.Sunday is 0
+		days := (cs.Interval - 1 - int(t.Weekday()) + 7) % 7
+		next := wallTime(t.Year(), t.Month(), t.Day()+days, startTimeHour, startTimeMinute, 0, loc)
+		if !next.After(t) {
+			next = wallTime(t.Year(), t.Month(), t.Day()+days+7, startTimeHour, startTimeMinute, 0, loc)
+		}
+		return next
 
-	// Start the cleaner for the following day with start time
-	c.NextRun = time.Date(time.Now().Local().Year(), time.Now().Local().Month(),
-		time.Now().Local().Day()+1, startTimeHour, startTimeMinute, 0, 0, time.Local).Unix()
+	case MONTHLY:
+		next := monthDay(t.Year(), t.Month(), cs.Interval, startTimeHour, startTimeMinute, loc)
+		if !next.After(t) {
+			next = monthDay(t.Year(), t.Month()+1, cs.Interval, startTimeHour, startTimeMinute, loc)
+		}
+		return next
+
+	case CRON:
+		// Error rejects the expressions that fail here, a cleaner that skipped
+		// the validation never runs
+		ce, err := parseCronExpr(cs.CronExpr)
+		if err != nil {
+			return t.AddDate(cronSearchYears, 0, 0)
+		}
+		if next := ce.next(t); !next.IsZero() {
+			return next
+		}
+		return t.AddDate(cronSearchYears, 0, 0)
+	}
+	return t.Add(time.Second)
 }
 
-// CleanWeekly runs the cleaner weekly
-func (c *Cleaner) CleanWeekly() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// period returns the time between the runs of the frequently schedule
+func (cs *CleanerSchedule) period() time.Duration {
+	value := cs.IntervalValue
+	if value <= 0 {
+		value = 1
+	}
 
-	// Get the start time hour and minute
-	startTimeHour, startTimeMinute := GetTime(c.Schedule.StartTime)
-	dayOfWeek := c.Schedule.Interval - 1
+	switch cs.Interval {
+	case EVERY_MINUTE:
+		return time.Minute * time.Duration(value)
+	case EVERY_HOUR:
+		return time.Hour * time.Duration(value)
+	}
+	return time.Second * time.Duration(value)
+}
 
-	// Find the next dayOfWeek in the future from the current day
-	nextDayOfWeek := time.Now().Local().AddDate(0, 0, (int(dayOfWeek) + int(time.Now().Local().Weekday()) - 1))
-	c.NextRun = time.Date(nextDayOfWeek.Year(), nextDayOfWeek.Month(),
-		nextDayOfWeek.Day(), startTimeHour, startTimeMinute, 0, 0, time.Local).Unix()
+// dueRuns returns the number of runs of the schedule from nextRun up to now,
+// at least 1, and the first run after now
+func (cs *CleanerSchedule) dueRuns(nextRun, now time.Time) (int, time.Time) {
+	if cs.ScheduleType == FREQUENTLY {
+		// Count the runs at once, there can be many when it runs every second
+		period := cs.period()
+		due := int(now.Sub(nextRun)/period) + 1
+		return due, nextRun.Add(period * time.Duration(due))
+	}
+
+	due := 0
+	for !nextRun.After(now) {
+		due++
+		nextRun = cs.nextRunAfter(nextRun)
+	}
+	return due, nextRun
 }
 
-// CleanMonthly runs the cleaner monthly
-func (c *Cleaner) CleanMonthly() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// monthDay returns the time of the day of the month, the day is moved to the
+// last day of the month when the month is shorter, e.g. 31 in April is April 30
+func monthDay(year int, month time.Month, day, hour, minute int, loc *time.Location) time.Time {
+	// The day 0 of the next month is the last day of the month
+	if last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+		day = last
+	}
+	return wallTime(year, month, day, hour, minute, 0, loc)
+}
+
+// wallTime returns the instant the wall clock of the location shows the date and
+// time, which are normalized like the ones of time.Date. A time that does not
+// exist because the clocks move forward is moved to the first instant after the
+// gap, e.g. 02:30 becomes 03:00, and a time that happens twice because the
+// clocks move back is the first of the two.
+func wallTime(year int, month time.Month, day, hour, minute, sec int, loc *time.Location) time.Time {
+	wall := time.Date(year, month, day, hour, minute, sec, 0, time.UTC).Unix()
+	offsetAt := func(unix int64) int64 {
+		_, offset := time.Unix(unix, 0).In(loc).Zone()
+		return int64(offset)
+	}
 
-	// Get the start time hour and minute
-	startTimeHour, startTimeMinute := GetTime(c.Schedule.StartTime)
+	// The offsets a day before and after, the clocks do not change twice in between
+	before, after := offsetAt(wall-86400), offsetAt(wall+86400)
+	early, late := wall-before, wall-after
+	if early > late {
+		early, late = late, early
+	}
+	switch {
+	case offsetAt(early) == wall-early:
+		return time.Unix(early, 0).In(loc)
+	case offsetAt(late) == wall-late:
+		return time.Unix(late, 0).In(loc)
+	}
 
-	// Find the next dayOfMonth in the future from the current day
-	c.NextRun = time.Date(time.Now().Local().Year(), time.Now().Local().Month()+1,
-		c.Schedule.Interval, startTimeHour, startTimeMinute, 0, 0, time.Local).Unix()
+	// The time is in the gap, find the instant the clocks moved forward
+	for late-early > 1 {
+		mid := early + (late-early)/2
+		if offsetAt(mid) == before {
+			early = mid
+		} else {
+			late = mid
+		}
+	}
+	return time.Unix(late, 0).In(loc)
 }
 
-// GetTime returns the hour and minute of the time
+// GetTime returns the hour and minute of the HH:MM or HH:MM:SS time, 0 and 0 if it is invalid
 func GetTime(startTime string) (int, int) {
-	// Get the start time hour and minute
-	startTimeHour, err := strconv.Atoi(strings.Split(startTime, ":")[0])
+	hour, minute, err := parseStartTime(startTime)
 	if err != nil {
 		return 0, 0
 	}
+	return hour, minute
+}
 
-	startTimeMinute, err := strconv.Atoi(strings.Split(startTime, ":")[1])
+// parseStartTime returns the hour and the minute of a HH:MM or HH:MM:SS start time,
+// the hour is from 0 to 23, the minute and the second from 0 to 59. The seconds
+// are validated and ignored, the schedules run on the minute.
+func parseStartTime(startTime string) (int, int, error) {
+	parts := strings.Split(startTime, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return 0, 0, fmt.Errorf("expected HH:MM or HH:MM:SS, got %q", startTime)
+	}
+	hour, err := startTimeField(parts[0], 23)
 	if err != nil {
-		return 0, 0
+		return 0, 0, fmt.Errorf("hour: %w", err)
+	}
+	minute, err := startTimeField(parts[1], 59)
+	if err != nil {
+		return 0, 0, fmt.Errorf("minute: %w", err)
+	}
+	if len(parts) == 3 {
+		if _, err := startTimeField(parts[2], 59); err != nil {
+			return 0, 0, fmt.Errorf("second: %w", err)
+		}
 	}
-	return startTimeHour, startTimeMinute
+	return hour, minute, nil
+}
+
+// startTimeField returns the value of a field of one or two digits of a start time
+func startTimeField(field string, max int) (int, error) {
+	if len(field) == 0 || len(field) > 2 {
+		return 0, fmt.Errorf("invalid value: %q", field)
+	}
+	for i := 0; i < len(field); i++ {
+		if field[i] < '0' || field[i] > '9' {
+			return 0, fmt.Errorf("invalid value: %q", field)
+		}
+	}
+	v, _ := strconv.Atoi(field)
+	if v > max {
+		return 0, fmt.Errorf("out of range 0-%d: %q", max, field)
+	}
+	return v, nil
+}
+
+// validateStartTime returns ErrInvalidSchedule if the start time is not a valid HH:MM or HH:MM:SS time
+func validateStartTime(startTime string) error {
+	if _, _, err := parseStartTime(startTime); err != nil {
+		return fmt.Errorf("%w: invalid start time: %v", ErrInvalidSchedule, err)
+	}
+	return nil
 }
 
 // getSchedTypeName returns the schedule type name
@@ -512,6 +842,8 @@ func getSchedTypeName(schedule *CleanerSchedule) string {
 		return WEEKLY_SCHEDULE_TYPE
 	case MONTHLY:
 		return MONTHLY_SCHEDULE_TYPE
+	case CRON:
+		return CRON_SCHEDULE_TYPE
 	}
 	return ""
 }
@@ -1,8 +1,14 @@
 package mem
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
+	_ "time/tzdata" // the time zones of the DST tests
+
+	"github.com/itrepablik/mem/memtest"
 )
 
 func TestGetTime(t *testing.T) {
@@ -13,6 +19,38 @@ func TestGetTime(t *testing.T) {
 		t.Errorf("GetTime failed")
 	}
 	t.Logf("GetTime: %d, %d", startTimeHour, startTimeMinute)
+
+	// A time without minutes does not panic
+	if h, m := GetTime("3"); h != 0 || m != 0 {
+		t.Errorf("GetTime(\"3\"): got %d, %d", h, m)
+	}
+	if h, m := GetTime("23:59"); h != 23 || m != 59 {
+		t.Errorf("GetTime(\"23:59\"): got %d, %d", h, m)
+	}
+}
+
+// startTimeOption is a CleanerOption implemented outside of the package
+type startTimeOption struct {
+	startTime string
+}
+
+func (o *startTimeOption) IntervalOpt() int      { return 0 }
+func (o *startTimeOption) IntervalValueOpt() int { return 0 }
+func (o *startTimeOption) StartTimeOpt() string  { return o.startTime }
+func (o *startTimeOption) SetInterval(int)       {}
+func (o *startTimeOption) SetIntervalValue(int)  {}
+func (o *startTimeOption) SetStartTime(s string) { o.startTime = s }
+func (o *startTimeOption) Error() error          { return nil }
+
+func TestNewCleanerCustomOption(t *testing.T) {
+	cleaner, err := NewCleaner(DAILY, &startTimeOption{startTime: "03:00"}, WithMisfire(MISFIRE_SKIP), WithLocation(time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := cleaner.Schedule
+	if cs.StartTime != "03:00" || cs.Misfire != MISFIRE_SKIP || cs.Location != time.UTC {
+		t.Errorf("the options were not applied: %+v", cs)
+	}
 }
 
 func TestNewCleaner(t *testing.T) {
@@ -24,16 +62,391 @@ func TestNewCleaner(t *testing.T) {
 	m := &MemData{
 		Key:    "key",
 		Value:  []byte("value"),
-		Expire: time.Now().Add(time.Second * 3).Unix(),
+		Expire: time.Now().Add(-time.Second).Unix(),
 	}
 	err := Set(m)
 	if err != nil {
 		t.Errorf("Error setting data: %s", err)
 	}
 
-	cleaner, err := NewCleaner(FREQUENTLY, WithIntervalValue(EVERY_SECOND, 3))
+	cleaner, err := NewCleaner(FREQUENTLY, WithIntervalValue(EVERY_SECOND, 1))
+	if err != nil {
+		t.Fatalf("Error creating a new cleaner: %s", err)
+	}
+
+	// Run the cleaner until the expired data is cleaned
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil && c.Stats().Expirations == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		cancel()
+	}()
+	cleaner.RunContext(ctx, c)
+	if n := c.Stats().Expirations; n != 1 {
+		t.Errorf("the cleaner removed %d data, want 1", n)
+	}
+}
+
+// blockingCache is a Cleanable whose cleaning blocks until it is released
+type blockingCache struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingCache) CleanExpired() int {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-b.release
+	return 0
+}
+
+func TestCleanerStopWaits(t *testing.T) {
+	cleaner, err := NewCleaner(FREQUENTLY, WithIntervalValue(EVERY_SECOND, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &blockingCache{started: make(chan struct{}), release: make(chan struct{})}
+	h := cleaner.Start(b)
+
+	select {
+	case <-b.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cleaner did not run")
+	}
+
+	// Stop waits for the cleaning in progress
+	stopped := make(chan struct{})
+	go func() {
+		h.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while cleaning")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(b.release)
+	<-stopped
+	h.Wait()
+}
+
+func TestCleanerStopRemovesSchedule(t *testing.T) {
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	cleaner, err := NewCleaner(FREQUENTLY, WithIntervalValue(EVERY_HOUR, 1), WithCleanerClock(clk))
 	if err != nil {
-		t.Errorf("Error creating a new cleaner: %s", err)
+		t.Fatal(err)
+	}
+	h := cleaner.Start(NewCache())
+
+	// The cleaner is listed while it runs
+	clk.BlockUntil(1)
+	cleaner.mu.RLock()
+	taskName := cleaner.TaskName
+	cleaner.mu.RUnlock()
+	if _, err := TS.GetCleanerSchedule(taskName); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Stop()
+	if _, err := TS.GetCleanerSchedule(taskName); !errors.Is(err, ErrCleanerNotFound) {
+		t.Errorf("GetCleanerSchedule after Stop: got %v, want ErrCleanerNotFound", err)
+	}
+}
+
+func TestExecRunnerRecordsRun(t *testing.T) {
+	c := NewCache()
+	c.Set(&MemData{Key: "old", Value: []byte("value"), Expire: time.Now().Add(-time.Hour).Unix()})
+
+	cleaner, err := NewCleaner(FREQUENTLY, WithIntervalValue(EVERY_HOUR, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleaner.AddCleaner()
+
+	// Make the cleaner due now
+	cleaner.NextRun = time.Now().Local().Unix()
+	UpdateCleaner(cleaner, cleaner.TaskName)
+	execRunner(cleaner, c)
+
+	s, err := TS.GetCleanerSchedule(cleaner.TaskName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Runs != 1 || s.LastRemoved != 1 || s.TotalRemoved != 1 || s.LastRun == 0 {
+		t.Errorf("run data was not recorded: %+v", s)
+	}
+	if s.NextRun <= time.Now().Unix() {
+		t.Errorf("next run was not updated: %d", s.NextRun)
+	}
+}
+
+// countingCache is a Cleanable that counts the cleanings
+type countingCache struct {
+	mu   sync.Mutex
+	runs int
+}
+
+func (cc *countingCache) CleanExpired() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.runs++
+	return 0
+}
+
+func (cc *countingCache) count() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.runs
+}
+
+func TestCleanerMisfire(t *testing.T) {
+	tests := []struct {
+		name    string
+		misfire int
+		runs    int
+		missed  int64
+	}{
+		{"default", 0, 2, 9},
+		{"run once", MISFIRE_RUN_ONCE, 2, 9},
+		{"run all", MISFIRE_RUN_ALL, 11, 0},
+		{"skip", MISFIRE_SKIP, 1, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+			cleaner, err := NewCleaner(FREQUENTLY, WithIntervalValue(EVERY_MINUTE, 1), WithMisfire(tt.misfire),
+				WithCleanerClock(clk))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cc := &countingCache{}
+			h := cleaner.Start(cc)
+			defer h.Stop()
+
+			// A run on time
+			clk.BlockUntil(1)
+			clk.Advance(time.Minute)
+			clk.BlockUntil(1)
+			if n := cc.count(); n != 1 {
+				t.Fatalf("got %d runs on time, want 1", n)
+			}
+
+			// The cleaner is late by 10 runs
+			clk.Advance(10 * time.Minute)
+			clk.BlockUntil(1)
+			if n := cc.count(); n != tt.runs {
+				t.Errorf("got %d runs, want %d", n, tt.runs)
+			}
+
+			cleaner.mu.RLock()
+			defer cleaner.mu.RUnlock()
+			if cleaner.Missed != tt.missed {
+				t.Errorf("got %d missed runs, want %d", cleaner.Missed, tt.missed)
+			}
+			if want := clk.Now().Add(time.Minute).Unix(); cleaner.NextRun != want {
+				t.Errorf("next run is %d, want %d", cleaner.NextRun, want)
+			}
+		})
+	}
+}
+
+func TestNextRunAfter(t *testing.T) {
+	// Friday, March 1 2024 10:00
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	date := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name     string
+		schedule CleanerSchedule
+		want     time.Time
+	}{
+		{"every 3 seconds", CleanerSchedule{ScheduleType: FREQUENTLY, Interval: EVERY_SECOND, IntervalValue: 3}, now.Add(3 * time.Second)},
+		{"every hour", CleanerSchedule{ScheduleType: FREQUENTLY, Interval: EVERY_HOUR}, now.Add(time.Hour)},
+		{"daily later today", CleanerSchedule{ScheduleType: DAILY, StartTime: "10:30"}, date(3, 1, 10, 30)},
+		{"daily tomorrow", CleanerSchedule{ScheduleType: DAILY, StartTime: "10:00"}, date(3, 2, 10, 0)},
+		{"weekly later this week", CleanerSchedule{ScheduleType: WEEKLY, Interval: SUNDAY, StartTime: "09:00"}, date(3, 3, 9, 0)},
+		{"weekly later today", CleanerSchedule{ScheduleType: WEEKLY, Interval: FRIDAY, StartTime: "11:00"}, date(3, 1, 11, 0)},
+		{"weekly next week", CleanerSchedule{ScheduleType: WEEKLY, Interval: FRIDAY, StartTime: "09:00"}, date(3, 8, 9, 0)},
+		{"weekly thursday", CleanerSchedule{ScheduleType: WEEKLY, Interval: THURSDAY, StartTime: "09:00"}, date(3, 7, 9, 0)},
+		{"monthly this month", CleanerSchedule{ScheduleType: MONTHLY, Interval: 15, StartTime: "02:00"}, date(3, 15, 2, 0)},
+		{"monthly next month", CleanerSchedule{ScheduleType: MONTHLY, Interval: 1, StartTime: "02:00"}, date(4, 1, 2, 0)},
+		{"monthly short month", CleanerSchedule{ScheduleType: MONTHLY, Interval: 31, StartTime: "02:00"}, date(3, 31, 2, 0)},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.nextRunAfter(now); !got.Equal(tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// The 31st in April is the last day of April
+	cs := CleanerSchedule{ScheduleType: MONTHLY, Interval: 31, StartTime: "02:00"}
+	if got := cs.nextRunAfter(date(3, 31, 2, 0)); !got.Equal(date(4, 30, 2, 0)) {
+		t.Errorf("monthly 31st after March: got %s", got)
+	}
+}
+
+func TestFirstRunAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	date := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name     string
+		schedule CleanerSchedule
+		want     time.Time
+	}{
+		{"every hour", CleanerSchedule{ScheduleType: FREQUENTLY, Interval: EVERY_HOUR}, now.Add(time.Hour)},
+		{"daily starts tomorrow", CleanerSchedule{ScheduleType: DAILY, StartTime: "10:30"}, date(3, 2, 10, 30)},
+		{"daily at midnight", CleanerSchedule{ScheduleType: DAILY, StartTime: "00:00"}, date(3, 2, 0, 0)},
+		{"weekly later this week", CleanerSchedule{ScheduleType: WEEKLY, Interval: SUNDAY, StartTime: "09:00"}, date(3, 3, 9, 0)},
+		{"monthly starts next month", CleanerSchedule{ScheduleType: MONTHLY, Interval: 15, StartTime: "02:00"}, date(4, 15, 2, 0)},
+		{"monthly short month", CleanerSchedule{ScheduleType: MONTHLY, Interval: 31, StartTime: "02:00"}, date(4, 30, 2, 0)},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.firstRunAfter(now); !got.Equal(tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// loadLocation returns the time zone or fails the test
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestWallTime(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		got  time.Time
+		want time.Time
+	}{
+		{"standard time", wallTime(2024, 1, 15, 2, 30, 0, ny), utc(1, 15, 7, 30)},
+		{"summer time", wallTime(2024, 7, 15, 2, 30, 0, ny), utc(7, 15, 6, 30)},
+		{"before the gap", wallTime(2024, 3, 10, 1, 59, 0, ny), utc(3, 10, 6, 59)},
+		{"in the gap", wallTime(2024, 3, 10, 2, 30, 0, ny), utc(3, 10, 7, 0)},
+		{"start of the gap", wallTime(2024, 3, 10, 2, 0, 0, ny), utc(3, 10, 7, 0)},
+		{"after the gap", wallTime(2024, 3, 10, 3, 0, 0, ny), utc(3, 10, 7, 0)},
+		{"repeated, first one", wallTime(2024, 11, 3, 1, 30, 0, ny), utc(11, 3, 5, 30)},
+		{"after the repeat", wallTime(2024, 11, 3, 2, 0, 0, ny), utc(11, 3, 7, 0)},
+		{"normalized date", wallTime(2024, 2, 30, 0, 0, 0, ny), utc(3, 1, 5, 0)},
+	}
+	for _, tt := range tests {
+		if !tt.got.Equal(tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, tt.got.UTC(), tt.want)
+		}
+	}
+}
+
+func TestNextRunAcrossDST(t *testing.T) {
+	ny := loadLocation(t, "America/New_York")
+	local := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, ny)
+	}
+
+	// runs returns the next runs of the schedule from the time
+	runs := func(cs CleanerSchedule, from time.Time, n int) []time.Time {
+		var next []time.Time
+		for i := 0; i < n; i++ {
+			from = cs.nextRunAfter(from)
+			next = append(next, from)
+		}
+		return next
+	}
+	tests := []struct {
+		name     string
+		schedule CleanerSchedule
+		from     time.Time
+		want     []time.Time
+	}{
+		{
+			// 02:30 does not exist on March 10, it runs at 03:00 once
+			"daily in the gap",
+			CleanerSchedule{ScheduleType: DAILY, StartTime: "02:30", Location: ny},
+			local(3, 9, 12, 0),
+			[]time.Time{time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), local(3, 11, 2, 30), local(3, 12, 2, 30)},
+		},
+		{
+			// 01:30 happens twice on November 3, it runs on the first one only
+			"daily in the repeat",
+			CleanerSchedule{ScheduleType: DAILY, StartTime: "01:30", Location: ny},
+			local(11, 2, 12, 0),
+			[]time.Time{time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), time.Date(2024, 11, 4, 6, 30, 0, 0, time.UTC)},
+		},
+		{
+			"weekly in the gap",
+			CleanerSchedule{ScheduleType: WEEKLY, Interval: SUNDAY, StartTime: "02:15", Location: ny},
+			local(3, 4, 0, 0),
+			[]time.Time{time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), local(3, 17, 2, 15)},
+		},
+		{
+			// Every 30 minutes, 02:00 and 02:30 collapse into 03:00
+			"cron in the gap",
+			CleanerSchedule{ScheduleType: CRON, CronExpr: "*/30 * * * *", Location: ny},
+			local(3, 10, 1, 15),
+			[]time.Time{local(3, 10, 1, 30), time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC)},
+		},
+		{
+			// Every hour, the repeated 01:00 does not run again
+			"cron in the repeat",
+			CleanerSchedule{ScheduleType: CRON, CronExpr: "0 * * * *", Location: ny},
+			local(11, 3, 0, 30),
+			[]time.Time{time.Date(2024, 11, 3, 5, 0, 0, 0, time.UTC), time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC), time.Date(2024, 11, 3, 8, 0, 0, 0, time.UTC)},
+		},
+	}
+	for _, tt := range tests {
+		got := runs(tt.schedule, tt.from, len(tt.want))
+		for i := range tt.want {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: run %d is %s, want %s", tt.name, i, got[i].UTC(), tt.want[i].UTC())
+			}
+		}
+	}
+
+	// From the second 01:15, the next run of every 30 minutes is 02:00
+	cs := CleanerSchedule{ScheduleType: CRON, CronExpr: "*/30 * * * *", Location: ny}
+	if got := cs.nextRunAfter(time.Date(2024, 11, 3, 6, 15, 0, 0, time.UTC)); !got.Equal(time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("cron after the repeat: got %s", got.UTC())
+	}
+}
+
+func TestWithLocation(t *testing.T) {
+	tokyo := loadLocation(t, "Asia/Tokyo")
+
+	// The server runs in UTC, the cleaner at 03:00 in Tokyo, 18:00 UTC
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	cleaner, err := NewCleaner(DAILY, WithStartTime("03:00"), WithLocation(tokyo), WithCleanerClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &countingCache{}
+	h := cleaner.Start(cc)
+	defer h.Stop()
+
+	clk.BlockUntil(1)
+	cleaner.mu.RLock()
+	nextRun := cleaner.NextRun
+	cleaner.mu.RUnlock()
+	if want := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC).Unix(); nextRun != want {
+		t.Errorf("next run is %s, want %s", time.Unix(nextRun, 0).UTC(), time.Unix(want, 0).UTC())
+	}
+
+	clk.Advance(8 * time.Hour)
+	clk.BlockUntil(1)
+	if n := cc.count(); n != 1 {
+		t.Errorf("got %d runs, want 1", n)
 	}
-	cleaner.Run(c)
 }
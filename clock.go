@@ -0,0 +1,39 @@
+package mem
+
+import "time"
+
+// Clock tells the time and makes timers for the cache and the cleaner, so the
+// tests can replace the real time, see the memtest package for a fake clock
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool) // returns the timer channel and its stop function
+}
+
+// realClock is the clock of the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
+// WithClock sets the clock that the expiry of the cache is based on
+func WithClock(clock Clock) CacheOption {
+	return func(c *Cache) {
+		c.clock = clock
+	}
+}
+
+// now returns the time of the clock of the cache
+func (c *Cache) now() time.Time {
+	return c.clock.Now()
+}
+
+// WithCleanerClock sets the clock that the schedule of the cleaner is based on
+func WithCleanerClock(clock Clock) CleanerOption {
+	return &CleanerSchedule{Clock: clock}
+}
@@ -0,0 +1,71 @@
+package mem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/itrepablik/mem/memtest"
+)
+
+func TestWithClock(t *testing.T) {
+	t.Parallel()
+
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	c := NewCache(WithClock(clk))
+	c.SetWithTTL("ttl", []byte("value"), time.Minute)
+	c.SetSliding("sliding", []byte("value"), time.Minute, 0)
+	c.Set(&MemData{Key: "expire", Value: []byte("value"), Expire: clk.Now().Add(time.Hour).Unix()})
+	if v, _ := c.Get("ttl"); string(v) != "value" {
+		t.Fatalf("ttl: got %q", v)
+	}
+	if c.shards[0].data["ttl"].Created != clk.Now().Unix() {
+		t.Error("the created timestamp is not from the clock")
+	}
+
+	// Reading the sliding data renews it from the time of the clock
+	clk.Advance(50 * time.Second)
+	if _, ok := c.Get("sliding"); !ok {
+		t.Error("sliding data expired early")
+	}
+	clk.Advance(20 * time.Second)
+	if _, ok := c.Get("ttl"); ok {
+		t.Error("ttl data did not expire")
+	}
+	if _, ok := c.Get("sliding"); !ok {
+		t.Error("sliding data was not renewed")
+	}
+
+	clk.Advance(time.Hour)
+	if n := c.CleanExpired(); n != 3 {
+		t.Errorf("CleanExpired removed %d, want 3", n)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("got %d live entries, want 0", n)
+	}
+}
+
+func TestWithCleanerClock(t *testing.T) {
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	c := NewCache(WithClock(clk))
+	c.SetWithTTL("key", []byte("value"), 12*time.Hour)
+
+	cleaner, err := NewCleaner(DAILY, WithStartTime("03:00"), WithCleanerClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := cleaner.Start(c)
+	defer h.Stop()
+
+	// The daily cleaner runs at 03:00 the next day, after the data expired
+	clk.BlockUntil(1)
+	clk.Advance(17 * time.Hour)
+	clk.BlockUntil(1)
+	if n := c.Stats().Expirations; n != 1 {
+		t.Errorf("the cleaner removed %d data, want 1", n)
+	}
+	cleaner.mu.RLock()
+	defer cleaner.mu.RUnlock()
+	if want := time.Date(2024, 3, 3, 3, 0, 0, 0, time.Local).Unix(); cleaner.NextRun != want {
+		t.Errorf("next run is %s, want %s", time.Unix(cleaner.NextRun, 0), time.Unix(want, 0))
+	}
+}
@@ -0,0 +1,84 @@
+package mem
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// IncrBy atomically adds delta to the counter stored by the key and returns the
+// new value. If the key is missing or expired, the counter is created with the
+// value of delta, expiring after the ttl, a ttl of 0 means never expire. An
+// existing counter keeps its expiry.
+//
+// Counters are stored as base 10 ASCII integers, e.g. "42", so Get returns
+// their text, and data set by other methods can be used as a counter if it
+// holds such a number.
+func (c *Cache) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Create the counter if the key is missing or expired
+	data, ok := s.data[key]
+	if !ok || data.isExpiredAt(c.now().UnixNano()) {
+		m := &MemData{Key: key, Value: []byte(strconv.FormatInt(delta, 10))}
+		m.setTTL(c.now(), ttl)
+		if err := c.insertLocked(s, m, c.now().Unix(), &evicted); err != nil {
+			return 0, err
+		}
+		atomic.AddUint64(&c.counters.sets, 1)
+		return delta, nil
+	}
+
+	n, err := strconv.ParseInt(string(data.Value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrNotNumeric, key)
+	}
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, fmt.Errorf("%w: %s", ErrOverflow, key)
+	}
+	n += delta
+
+	// Keep the expiry and the tags of the existing counter
+	m := &MemData{Value: []byte(strconv.FormatInt(n, 10)), Tags: data.Tags}
+	m.copyExpiry(data)
+	if err := c.replaceLocked(s, data, m, &evicted); err != nil {
+		return 0, err
+	}
+	atomic.AddUint64(&c.counters.replaces, 1)
+	return n, nil
+}
+
+// Incr atomically adds delta to the counter stored by the key, creating it without expiry if missing
+func (c *Cache) Incr(key string, delta int64) (int64, error) {
+	return c.IncrBy(key, delta, 0)
+}
+
+// Decr atomically subtracts delta from the counter stored by the key, creating it without expiry if missing
+func (c *Cache) Decr(key string, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, fmt.Errorf("%w: %s", ErrOverflow, key)
+	}
+	return c.IncrBy(key, -delta, 0)
+}
+
+// IncrBy atomically adds delta to the counter stored by the key in the default cache
+func IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	return defaultCache.IncrBy(key, delta, ttl)
+}
+
+// Incr atomically adds delta to the counter stored by the key in the default cache
+func Incr(key string, delta int64) (int64, error) {
+	return defaultCache.Incr(key, delta)
+}
+
+// Decr atomically subtracts delta from the counter stored by the key in the default cache
+func Decr(key string, delta int64) (int64, error) {
+	return defaultCache.Decr(key, delta)
+}
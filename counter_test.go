@@ -0,0 +1,85 @@
+package mem
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itrepablik/mem/memtest"
+)
+
+func TestIncr(t *testing.T) {
+	c := NewCache()
+
+	// A missing counter is created with the value of delta
+	if n, err := c.Incr("hits", 5); err != nil || n != 5 {
+		t.Fatalf("Incr: got %d, %v", n, err)
+	}
+	if n, err := c.Incr("hits", 2); err != nil || n != 7 {
+		t.Errorf("Incr: got %d, %v", n, err)
+	}
+	if n, err := c.Decr("hits", 10); err != nil || n != -3 {
+		t.Errorf("Decr: got %d, %v", n, err)
+	}
+
+	// Counters are stored as base 10 text
+	if v, ok := c.Get("hits"); !ok || string(v) != "-3" {
+		t.Errorf("Get: got %q, %v", v, ok)
+	}
+
+	c.Set(&MemData{Key: "name", Value: []byte("juan")})
+	if _, err := c.Incr("name", 1); !errors.Is(err, ErrNotNumeric) {
+		t.Errorf("Incr of text: got %v, want ErrNotNumeric", err)
+	}
+
+	c.Set(&MemData{Key: "max", Value: []byte("9223372036854775807")})
+	if _, err := c.Incr("max", 1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Incr past the maximum: got %v, want ErrOverflow", err)
+	}
+	if _, err := c.Decr("max", math.MinInt64); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Decr of the minimum: got %v, want ErrOverflow", err)
+	}
+}
+
+func TestIncrByTTL(t *testing.T) {
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	c := NewCache(WithClock(clk))
+
+	if n, err := c.IncrBy("rate", 1, 100*time.Millisecond); err != nil || n != 1 {
+		t.Fatalf("IncrBy: got %d, %v", n, err)
+	}
+
+	// Updates keep the expiry of the counter
+	clk.Advance(60 * time.Millisecond)
+	if n, err := c.IncrBy("rate", 1, time.Hour); err != nil || n != 2 {
+		t.Errorf("IncrBy: got %d, %v", n, err)
+	}
+	clk.Advance(60 * time.Millisecond)
+
+	// An expired counter starts over
+	if n, err := c.IncrBy("rate", 1, time.Hour); err != nil || n != 1 {
+		t.Errorf("IncrBy after expiry: got %d, %v", n, err)
+	}
+}
+
+func TestIncrConcurrent(t *testing.T) {
+	c := NewCache(WithShards(4))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Incr("counter", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := c.Get("counter"); string(v) != "5000" {
+		t.Errorf("counter: got %s, want 5000", v)
+	}
+}
@@ -0,0 +1,248 @@
+package mem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the @ shortcuts of the cron expressions, as 6 field expressions
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// cronMonths and cronDays are the names accepted in the month and day of week fields
+var (
+	cronMonths = map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}
+	cronDays = map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}
+)
+
+// cronSearchYears is how far ahead a run is searched for, enough to find the
+// 29th of February
+const cronSearchYears = 8
+
+// cronExpr is a parsed cron expression, every field is a bit set of the values it matches
+type cronExpr struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool // the day fields start with * or ?, see dayMatches
+}
+
+// parseCronExpr parses a standard 5 field cron expression (minute hour day-of-month
+// month day-of-week), a 6 field one with the seconds first, or a macro like @hourly.
+// The fields accept *, lists, ranges and steps, e.g. "*/15 9-17 * * MON-FRI".
+func parseCronExpr(expr string) (*cronExpr, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("expected 5 or 6 fields, got %d", len(fields))
+	}
+
+	ce := &cronExpr{
+		domStar: strings.HasPrefix(fields[3], "*") || strings.HasPrefix(fields[3], "?"),
+		dowStar: strings.HasPrefix(fields[5], "*") || strings.HasPrefix(fields[5], "?"),
+	}
+	var err error
+	if ce.second, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("second: %w", err)
+	}
+	if ce.minute, err = parseCronField(fields[1], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if ce.hour, err = parseCronField(fields[2], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if ce.dom, err = parseCronField(fields[3], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if ce.month, err = parseCronField(fields[4], 1, 12, cronMonths); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+
+	// Sunday is 0 or 7
+	if ce.dow, err = parseCronField(fields[5], 0, 7, cronDays); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	if ce.dow&(1<<7) != 0 {
+		ce.dow = ce.dow&^(1<<7) | 1
+	}
+	return ce, nil
+}
+
+// parseCronField returns the bit set of the values matched by the field, a comma
+// separated list of *, ?, a value, or a range a-b, each with an optional /step
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		hasStep := false
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step: %q", part)
+			}
+			rangePart, hasStep = part[:i], true
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			if hi, err = cronValue(bounds[1], names); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if lo, err = cronValue(rangePart, names); err != nil {
+				return 0, err
+			}
+			// A value with a step runs from the value to the end of the range
+			hi = lo
+			if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("out of range %d-%d: %q", min, max, part)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronValue returns the number or the name value
+func cronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %q", s)
+	}
+	return v, nil
+}
+
+// next returns the first time strictly after t that the expression matches on
+// the wall clock of the location of t, or the zero time if there is none in the
+// next years. The times the clocks skip or repeat follow wallTime, so the runs in
+// a DST gap happen once at its end and the repeated runs are not run again.
+func (ce *cronExpr) next(t time.Time) time.Time {
+	loc := t.Location()
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	for {
+		if wall = ce.nextWall(wall); wall.IsZero() {
+			return time.Time{}
+		}
+		next := wallTime(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), loc)
+		if next.After(t) {
+			return next
+		}
+	}
+}
+
+// nextWall returns the first wall clock time strictly after the wall clock time
+// that the expression matches, both in UTC to count every hour of every day once
+func (ce *cronExpr) nextWall(t time.Time) time.Time {
+	t = t.Add(time.Second)
+	yearLimit := t.Year() + cronSearchYears
+
+	// Move to the next matching value of one field at a time, from the month to
+	// the second, resetting the smaller fields. When a field wraps around, the
+	// larger fields changed and have to be checked again.
+	reset := false
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for ce.month&(1<<uint(t.Month())) == 0 {
+		if !reset {
+			reset = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !ce.dayMatches(t) {
+		if !reset {
+			reset = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for ce.hour&(1<<uint(t.Hour())) == 0 {
+		if !reset {
+			reset = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for ce.minute&(1<<uint(t.Minute())) == 0 {
+		if !reset {
+			reset = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for ce.second&(1<<uint(t.Second())) == 0 {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
+
+// dayMatches returns true if the day of t matches the day fields. Like the
+// standard cron, when both day fields are restricted either of them matching is
+// enough, otherwise both have to match.
+func (ce *cronExpr) dayMatches(t time.Time) bool {
+	domMatch := ce.dom&(1<<uint(t.Day())) != 0
+	dowMatch := ce.dow&(1<<uint(t.Weekday())) != 0
+	if ce.domStar || ce.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
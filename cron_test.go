@@ -0,0 +1,112 @@
+package mem
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/itrepablik/mem/memtest"
+)
+
+func TestCronNext(t *testing.T) {
+	// Friday, March 1 2024 10:07:30 UTC
+	now := time.Date(2024, 3, 1, 10, 7, 30, 0, time.UTC)
+	date := func(year int, month time.Month, day, hour, minute, second int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+	}
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", date(2024, 3, 1, 10, 8, 0)},
+		{"* * * * * *", date(2024, 3, 1, 10, 7, 31)},
+		{"*/15 9-17 * * MON-FRI", date(2024, 3, 1, 10, 15, 0)},
+		{"*/15 9-17 * * mon-fri", date(2024, 3, 1, 10, 15, 0)},
+		{"0 2 1,15 * *", date(2024, 3, 15, 2, 0, 0)},
+		{"30 */10 * * * *", date(2024, 3, 1, 10, 10, 30)},
+		{"0 0 * * 0", date(2024, 3, 3, 0, 0, 0)},
+		{"0 0 * * 7", date(2024, 3, 3, 0, 0, 0)},
+		{"0 0 * * SAT,SUN", date(2024, 3, 2, 0, 0, 0)},
+		{"5/20 * * * *", date(2024, 3, 1, 10, 25, 0)},
+		{"0 12 29 2 *", date(2028, 2, 29, 12, 0, 0)},
+		{"0 0 31 * *", date(2024, 3, 31, 0, 0, 0)},
+		{"0 9 * JAN-FEB *", date(2025, 1, 1, 9, 0, 0)},
+		{"0 0 13 * FRI", date(2024, 3, 8, 0, 0, 0)}, // the 13th or a Friday
+		{"@hourly", date(2024, 3, 1, 11, 0, 0)},
+		{"@daily", date(2024, 3, 2, 0, 0, 0)},
+		{"@weekly", date(2024, 3, 3, 0, 0, 0)},
+		{"@monthly", date(2024, 4, 1, 0, 0, 0)},
+		{"@yearly", date(2025, 1, 1, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		ce, err := parseCronExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := ce.next(now); !got.Equal(tt.want) {
+			t.Errorf("%q: got %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * FOO *",
+		"@often",
+	} {
+		if _, err := parseCronExpr(expr); err == nil {
+			t.Errorf("%q: no error", expr)
+		}
+	}
+}
+
+func TestCronSchedule(t *testing.T) {
+	schedules := []*CleanerSchedule{
+		{ScheduleType: CRON, CronExpr: "* * *"},
+		{ScheduleType: CRON, CronExpr: "0 0 30 2 *"}, // never runs
+		{ScheduleType: CRON, CronExpr: "0 0 * * *", StartTime: "10:00"},
+		{ScheduleType: DAILY, StartTime: "10:00", CronExpr: "0 0 * * *"},
+	}
+	for _, cs := range schedules {
+		if err := cs.Error(); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Error of %+v: got %v", cs, err)
+		}
+	}
+
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 7, 30, 0, time.Local))
+	cleaner, err := NewCleaner(CRON, WithCronExpr("*/15 9-17 * * MON-FRI"), WithCleanerClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &countingCache{}
+	h := cleaner.Start(cc)
+	defer h.Stop()
+
+	// 10:15 on Friday, then 09:00 on Monday after the last run of Friday at 17:45
+	clk.BlockUntil(1)
+	clk.Advance(7*time.Minute + 30*time.Second)
+	clk.BlockUntil(1)
+	if n := cc.count(); n != 1 {
+		t.Errorf("got %d runs, want 1", n)
+	}
+	clk.Advance(7*time.Hour + 30*time.Minute)
+	clk.BlockUntil(1)
+
+	cleaner.mu.RLock()
+	defer cleaner.mu.RUnlock()
+	if want := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local).Unix(); cleaner.NextRun != want {
+		t.Errorf("next run is %s, want %s", time.Unix(cleaner.NextRun, 0), time.Unix(want, 0))
+	}
+}
@@ -0,0 +1,26 @@
+package mem
+
+import "errors"
+
+// Cache errors, the returned errors wrap them with the key, match them with errors.Is
+var (
+	ErrKeyExists   = errors.New("key already exists")
+	ErrKeyNotFound = errors.New("key not found")
+	ErrExpired     = errors.New("key expired")
+	ErrTooLarge    = errors.New("entry too large") // a single entry is larger than the memory budget
+	ErrNotNumeric  = errors.New("value is not a number")
+	ErrOverflow    = errors.New("counter overflow")
+	ErrConflict    = errors.New("version conflict") // matched by the ConflictError of CompareAndSwap
+)
+
+// Persistence errors
+var (
+	ErrBadSnapshot = errors.New("invalid snapshot") // corrupted or of an unknown version
+	ErrLogClosed   = errors.New("write log is closed")
+)
+
+// Cleaner errors
+var (
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	ErrCleanerNotFound = errors.New("cleaner not found")
+)
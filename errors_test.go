@@ -0,0 +1,97 @@
+package mem
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCacheErrors(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache()
+	c.Set(&MemData{Key: "key", Value: []byte("value")})
+	c.Set(&MemData{Key: "old", Value: []byte("value"), Expire: time.Now().Add(-time.Hour).Unix()})
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"Set existing", c.Set(&MemData{Key: "key", Value: []byte("value")}), ErrKeyExists},
+		{"Replace missing", c.Replace("missing", &MemData{Value: []byte("value")}), ErrKeyNotFound},
+		{"Replace expired", c.Replace("old", &MemData{Value: []byte("value")}), ErrExpired},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+
+	_, err := c.CompareAndSwap("missing", 1, []byte("value"))
+	if !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("CompareAndSwap missing: got %v", err)
+	}
+	_, err = c.CompareAndSwap("old", 1, []byte("value"))
+	if !errors.Is(err, ErrExpired) {
+		t.Errorf("CompareAndSwap expired: got %v", err)
+	}
+}
+
+func TestTypedCacheErrors(t *testing.T) {
+	t.Parallel()
+
+	c := NewTypedCache[int, string]()
+	c.Set(&TypedData[int, string]{Key: 1, Value: "one"})
+	c.Set(&TypedData[int, string]{Key: 2, Value: "two", Expire: time.Now().Add(-time.Hour).Unix()})
+
+	if err := c.Set(&TypedData[int, string]{Key: 1, Value: "one"}); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("Set existing: got %v", err)
+	}
+	if err := c.Replace(3, &TypedData[int, string]{Value: "three"}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Replace missing: got %v", err)
+	}
+	if err := c.Replace(2, &TypedData[int, string]{Value: "two"}); !errors.Is(err, ErrExpired) {
+		t.Errorf("Replace expired: got %v", err)
+	}
+}
+
+func TestScheduleErrors(t *testing.T) {
+	t.Parallel()
+
+	schedules := []*CleanerSchedule{
+		{ScheduleType: 42},
+		{ScheduleType: FREQUENTLY, Interval: 42},
+		{ScheduleType: FREQUENTLY, Interval: EVERY_MINUTE, StartTime: "10:00"},
+		{ScheduleType: DAILY},
+		{ScheduleType: DAILY, StartTime: "3"},
+		{ScheduleType: DAILY, StartTime: "ab:cd"},
+		{ScheduleType: DAILY, StartTime: "25:99"},
+		{ScheduleType: DAILY, StartTime: "10:00:60"},
+		{ScheduleType: DAILY, StartTime: "10:00:00:00"},
+		{ScheduleType: WEEKLY, Interval: MONDAY, StartTime: "24:00"},
+		{ScheduleType: MONTHLY, Interval: 1, StartTime: "10:60"},
+		{ScheduleType: WEEKLY, Interval: 42, StartTime: "10:00"},
+		{ScheduleType: MONTHLY, Interval: 32, StartTime: "10:00"},
+	}
+	for _, cs := range schedules {
+		if err := cs.Error(); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Error of %+v: got %v", cs, err)
+		}
+	}
+	if err := (&CleanerSchedule{ScheduleType: DAILY, StartTime: "10:00"}).Error(); err != nil {
+		t.Errorf("Error of a valid schedule: %v", err)
+	}
+
+	// The seconds are accepted and ignored
+	cleaner, err := NewCleaner(DAILY, WithStartTime(DEFAULT_START_TIME))
+	if err != nil {
+		t.Fatalf("NewCleaner with DEFAULT_START_TIME: %v", err)
+	}
+	if h, m := GetTime(cleaner.Schedule.StartTime); h != 0 || m != 0 {
+		t.Errorf("GetTime(DEFAULT_START_TIME): got %d, %d", h, m)
+	}
+	if h, m := GetTime("03:15:30"); h != 3 || m != 15 {
+		t.Errorf("GetTime(\"03:15:30\"): got %d, %d", h, m)
+	}
+}
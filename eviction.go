@@ -0,0 +1,161 @@
+package mem
+
+import "container/list"
+
+// EvictionPolicy decides which entry is evicted when the cache is full. The cache
+// informs the policy about every insert, access and removal of a key, all calls
+// are made while the cache lock is held so implementations need no locking.
+type EvictionPolicy interface {
+	OnInsert(key string)    // a new key is stored in the cache
+	OnAccess(key string)    // an existing key is read or replaced
+	OnRemove(key string)    // a key is removed from the cache for any reason
+	Victim() (string, bool) // the key to evict next, false if there is none
+}
+
+// PolicyFunc creates an eviction policy for a cache holding up to capacity
+// entries, capacity is 0 when the cache is only limited by its memory budget
+type PolicyFunc func(capacity int) EvictionPolicy
+
+// lruPolicy evicts the least recently used key
+type lruPolicy struct {
+	ll    *list.List               // front is the most recently used key
+	items map[string]*list.Element // key to list element lookup
+}
+
+// NewLRUPolicy returns a least recently used eviction policy
+func NewLRUPolicy(capacity int) EvictionPolicy {
+	return &lruPolicy{
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// OnInsert records a newly stored key as the most recently used
+func (p *lruPolicy) OnInsert(key string) {
+	if e, ok := p.items[key]; ok {
+		p.ll.MoveToFront(e)
+		return
+	}
+	p.items[key] = p.ll.PushFront(key)
+}
+
+// OnAccess marks the key as the most recently used
+func (p *lruPolicy) OnAccess(key string) {
+	if e, ok := p.items[key]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+// OnRemove forgets the key
+func (p *lruPolicy) OnRemove(key string) {
+	if e, ok := p.items[key]; ok {
+		p.ll.Remove(e)
+		delete(p.items, key)
+	}
+}
+
+// Victim returns the least recently used key
+func (p *lruPolicy) Victim() (string, bool) {
+	e := p.ll.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// fifoPolicy evicts the oldest inserted key regardless of how often it is used
+type fifoPolicy struct {
+	ll    *list.List               // front is the newest key
+	items map[string]*list.Element // key to list element lookup
+}
+
+// NewFIFOPolicy returns a first in, first out eviction policy
+func NewFIFOPolicy(capacity int) EvictionPolicy {
+	return &fifoPolicy{
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// OnInsert records a newly stored key as the newest
+func (p *fifoPolicy) OnInsert(key string) {
+	if _, ok := p.items[key]; !ok {
+		p.items[key] = p.ll.PushFront(key)
+	}
+}
+
+// OnAccess does nothing, the insertion order is not affected by reads
+func (p *fifoPolicy) OnAccess(key string) {}
+
+// OnRemove forgets the key
+func (p *fifoPolicy) OnRemove(key string) {
+	if e, ok := p.items[key]; ok {
+		p.ll.Remove(e)
+		delete(p.items, key)
+	}
+}
+
+// Victim returns the oldest inserted key
+func (p *fifoPolicy) Victim() (string, bool) {
+	e := p.ll.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// keyList is an LRU ordered list of keys, front is the most recently used
+type keyList struct {
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+// newKeyList returns a new empty key list
+func newKeyList() *keyList {
+	return &keyList{ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// has returns true if the key is in the list
+func (l *keyList) has(key string) bool {
+	_, ok := l.items[key]
+	return ok
+}
+
+// pushFront adds the key as the most recently used
+func (l *keyList) pushFront(key string) {
+	l.items[key] = l.ll.PushFront(key)
+}
+
+// moveToFront marks the key as the most recently used
+func (l *keyList) moveToFront(key string) {
+	l.ll.MoveToFront(l.items[key])
+}
+
+// remove removes the key from the list
+func (l *keyList) remove(key string) {
+	if e, ok := l.items[key]; ok {
+		l.ll.Remove(e)
+		delete(l.items, key)
+	}
+}
+
+// removeBack removes and returns the least recently used key
+func (l *keyList) removeBack() string {
+	key := l.ll.Back().Value.(string)
+	l.remove(key)
+	return key
+}
+
+// len returns the number of keys in the list
+func (l *keyList) len() int {
+	return l.ll.Len()
+}
+
+// back returns the least recently used key without removing it
+func (l *keyList) back() (string, bool) {
+	e := l.ll.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
@@ -0,0 +1,255 @@
+package mem
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMaxEntriesEvictsLRU(t *testing.T) {
+	c := NewCache(WithMaxEntries(3))
+	for i := 1; i <= 3; i++ {
+		if err := c.Set(&MemData{Key: fmt.Sprintf("key%d", i), Value: []byte("value")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Access key1 so key2 becomes the least recently used entry
+	if _, ok := c.Get("key1"); !ok {
+		t.Fatal("Get failed")
+	}
+
+	if err := c.Set(&MemData{Key: "key4", Value: []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	for _, key := range []string{"key1", "key3", "key4"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if len(c.shards[0].data) != 3 {
+		t.Errorf("cache has %d entries, want 3", len(c.shards[0].data))
+	}
+	if c.Evictions() != 1 {
+		t.Errorf("Evictions: got %d, want 1", c.Evictions())
+	}
+}
+
+func TestMaxEntriesDelete(t *testing.T) {
+	c := NewCache(WithMaxEntries(2))
+	c.Set(&MemData{Key: "key1", Value: []byte("value")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value")})
+
+	// Deleting frees a slot, so the next Set must not evict anything
+	c.Delete("key1")
+	c.Set(&MemData{Key: "key3", Value: []byte("value")})
+	if c.Evictions() != 0 {
+		t.Errorf("Evictions: got %d, want 0", c.Evictions())
+	}
+
+	c.ClearAll()
+	c.Set(&MemData{Key: "key4", Value: []byte("value")})
+	c.Set(&MemData{Key: "key5", Value: []byte("value")})
+	c.Set(&MemData{Key: "key6", Value: []byte("value")})
+	if _, ok := c.Get("key4"); ok {
+		t.Error("key4 should have been evicted")
+	}
+	if c.Evictions() != 1 {
+		t.Errorf("Evictions: got %d, want 1", c.Evictions())
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	// Each entry is 4 bytes of key and 6 bytes of value
+	c := NewCache(WithMaxBytes(25))
+	c.Set(&MemData{Key: "key1", Value: []byte("value1")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value2")})
+	if c.shards[0].size != 20 {
+		t.Errorf("size: got %d, want 20", c.shards[0].size)
+	}
+
+	// The third entry does not fit, so the least recently used one is evicted
+	c.Get("key1")
+	c.Set(&MemData{Key: "key3", Value: []byte("value3")})
+	if _, ok := c.Get("key2"); ok {
+		t.Error("key2 should have been evicted")
+	}
+	if c.shards[0].size != 20 || c.Evictions() != 1 {
+		t.Errorf("size: got %d, evictions: got %d", c.shards[0].size, c.Evictions())
+	}
+
+	// Growing a value by Replace evicts other entries, never the replaced one
+	err := c.Replace("key3", &MemData{Value: []byte("a larger value")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("key1"); ok {
+		t.Error("key1 should have been evicted")
+	}
+	if v, ok := c.Get("key3"); !ok || string(v) != "a larger value" {
+		t.Errorf("Replace failed: %q, %v", v, ok)
+	}
+	if c.shards[0].size != 18 {
+		t.Errorf("size: got %d, want 18", c.shards[0].size)
+	}
+
+	c.Delete("key3")
+	if c.shards[0].size != 0 {
+		t.Errorf("size after delete: got %d, want 0", c.shards[0].size)
+	}
+}
+
+func TestMaxBytesTooLarge(t *testing.T) {
+	c := NewCache(WithMaxBytes(10))
+	c.Set(&MemData{Key: "key1", Value: []byte("v1")})
+
+	err := c.Set(&MemData{Key: "key2", Value: []byte("a value larger than the budget")})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Set: got %v, want ErrTooLarge", err)
+	}
+	err = c.Replace("key1", &MemData{Value: []byte("a value larger than the budget")})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Replace: got %v, want ErrTooLarge", err)
+	}
+
+	// The rejected values must not evict anything
+	if _, ok := c.Get("key1"); !ok || c.Evictions() != 0 {
+		t.Error("rejected value evicted existing data")
+	}
+}
+
+func TestFIFOPolicy(t *testing.T) {
+	c := NewCache(WithMaxEntries(2), WithEvictionPolicy(NewFIFOPolicy))
+	c.Set(&MemData{Key: "key1", Value: []byte("value")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value")})
+
+	// Reads do not protect the oldest entry from eviction
+	c.Get("key1")
+	c.Set(&MemData{Key: "key3", Value: []byte("value")})
+	if _, ok := c.Get("key1"); ok {
+		t.Error("oldest entry was not evicted")
+	}
+	if _, ok := c.Get("key2"); !ok {
+		t.Error("key2 was evicted")
+	}
+}
+
+func TestLFUPolicy(t *testing.T) {
+	c := NewCache(WithMaxEntries(3), WithEvictionPolicy(NewLFUPolicy))
+	c.Set(&MemData{Key: "key1", Value: []byte("value")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value")})
+	c.Set(&MemData{Key: "key3", Value: []byte("value")})
+
+	// key2 is the only entry that is not used again
+	c.Get("key1")
+	c.Get("key1")
+	c.Get("key3")
+
+	c.Set(&MemData{Key: "key4", Value: []byte("value")})
+	if _, ok := c.shards[0].data["key2"]; ok {
+		t.Error("least frequently used entry was not evicted")
+	}
+
+	// Among equal frequencies the least recently used one goes first
+	c.Get("key4")
+	c.Set(&MemData{Key: "key5", Value: []byte("value")})
+	if _, ok := c.shards[0].data["key3"]; ok {
+		t.Error("key3 should have been evicted")
+	}
+}
+
+// scanResistance fills the cache with hot keys used many times, then scans
+// through one-hit keys and returns how many of the hot keys survived
+func scanResistance(t *testing.T, newPolicy PolicyFunc) int {
+	t.Helper()
+
+	c := NewCache(WithMaxEntries(100), WithEvictionPolicy(newPolicy))
+	for i := 0; i < 50; i++ {
+		c.Set(&MemData{Key: fmt.Sprintf("hot%d", i), Value: []byte("value")})
+	}
+	for n := 0; n < 5; n++ {
+		for i := 0; i < 50; i++ {
+			c.Get(fmt.Sprintf("hot%d", i))
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set(&MemData{Key: fmt.Sprintf("scan%d", i), Value: []byte("value")})
+	}
+
+	if len(c.shards[0].data) != 100 {
+		t.Errorf("cache has %d entries, want 100", len(c.shards[0].data))
+	}
+	survived := 0
+	for i := 0; i < 50; i++ {
+		if _, ok := c.shards[0].data[fmt.Sprintf("hot%d", i)]; ok {
+			survived++
+		}
+	}
+	return survived
+}
+
+func TestScanResistantPolicies(t *testing.T) {
+	policies := map[string]PolicyFunc{
+		"LFU":     NewLFUPolicy,
+		"ARC":     NewARCPolicy,
+		"TinyLFU": NewTinyLFUPolicy,
+	}
+	for name, newPolicy := range policies {
+		if survived := scanResistance(t, newPolicy); survived < 45 {
+			t.Errorf("%s: %d of 50 hot keys survived the scan", name, survived)
+		}
+	}
+
+	// LRU keeps only the most recent keys, so the scan flushes the hot keys out
+	if survived := scanResistance(t, NewLRUPolicy); survived != 0 {
+		t.Errorf("LRU: %d of 50 hot keys survived the scan", survived)
+	}
+}
+
+func TestMaxBytesPolicies(t *testing.T) {
+	for _, newPolicy := range []PolicyFunc{NewLRUPolicy, NewLFUPolicy, NewFIFOPolicy, NewARCPolicy, NewTinyLFUPolicy} {
+		c := NewCache(WithMaxBytes(1000), WithEvictionPolicy(newPolicy))
+		for i := 0; i < 500; i++ {
+			c.Set(&MemData{Key: fmt.Sprintf("key%03d", i), Value: []byte("value")})
+			c.Get(fmt.Sprintf("key%03d", i/2))
+		}
+		if c.shards[0].size > 1000 || len(c.shards[0].data) != 90 {
+			t.Errorf("size: got %d bytes and %d entries, want at most 1000 bytes and 90 entries", c.shards[0].size, len(c.shards[0].data))
+		}
+	}
+}
+
+// randomPolicy is a user supplied policy that evicts the first key found in its map
+type randomPolicy struct {
+	keys map[string]bool
+}
+
+func (p *randomPolicy) OnInsert(key string) { p.keys[key] = true }
+func (p *randomPolicy) OnAccess(key string) {}
+func (p *randomPolicy) OnRemove(key string) { delete(p.keys, key) }
+func (p *randomPolicy) Victim() (string, bool) {
+	for key := range p.keys {
+		return key, true
+	}
+	return "", false
+}
+
+func TestCustomPolicy(t *testing.T) {
+	newPolicy := func(capacity int) EvictionPolicy {
+		return &randomPolicy{keys: make(map[string]bool)}
+	}
+
+	c := NewCache(WithMaxEntries(10), WithEvictionPolicy(newPolicy))
+	for i := 0; i < 100; i++ {
+		c.Set(&MemData{Key: fmt.Sprintf("key%d", i), Value: []byte("value")})
+	}
+	if len(c.shards[0].data) != 10 || c.Evictions() != 90 {
+		t.Errorf("got %d entries and %d evictions, want 10 and 90", len(c.shards[0].data), c.Evictions())
+	}
+	if len(c.shards[0].policy.(*randomPolicy).keys) != 10 {
+		t.Error("the policy was not informed about the removed keys")
+	}
+}
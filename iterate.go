@@ -0,0 +1,139 @@
+package mem
+
+import (
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// entry is a key and value pair copied out of a shard
+type entry struct {
+	key   string
+	value []byte
+}
+
+// liveEntries returns the live data of the shard accepted by match, so the
+// caller can use it without holding the lock
+func (s *shard) liveEntries(now int64, match func(key string) bool) []entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]entry, 0, len(s.data))
+	for key, data := range s.data {
+		if !data.isExpiredAt(now) && (match == nil || match(key)) {
+			entries = append(entries, entry{key: key, value: data.Value})
+		}
+	}
+	return entries
+}
+
+// Keys returns the keys of the live data, in no particular order
+func (c *Cache) Keys() []string {
+	var keys []string
+	for _, s := range c.shards {
+		for _, e := range s.liveEntries(c.now().UnixNano(), nil) {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// Len returns the number of live data in the cache
+func (c *Cache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		now := c.now().UnixNano()
+		s.mu.RLock()
+		for _, data := range s.data {
+			if !data.isExpiredAt(now) {
+				n++
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Range calls fn for each live data until fn returns false, in no particular
+// order. The data of a shard is copied before fn is called, so fn may use the
+// cache and does not see the changes made since. The value must not be modified.
+func (c *Cache) Range(fn func(key string, value []byte) bool) {
+	for _, s := range c.shards {
+		for _, e := range s.liveEntries(c.now().UnixNano(), nil) {
+			if !fn(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// ScanPrefix returns the live data whose keys start with the prefix
+func (c *Cache) ScanPrefix(prefix string) map[string][]byte {
+	data := make(map[string][]byte)
+	for _, s := range c.shards {
+		for _, e := range s.liveEntries(c.now().UnixNano(), func(key string) bool { return strings.HasPrefix(key, prefix) }) {
+			data[e.key] = e.value
+		}
+	}
+	return data
+}
+
+// DeleteMatching deletes the data whose keys match the glob pattern, see path.Match
+// for the syntax, one shard at a time. It returns the number of live data deleted,
+// or path.ErrBadPattern if the pattern is malformed.
+func (c *Cache) DeleteMatching(pattern string) (int, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, err
+	}
+
+	removed, expired := 0, 0
+	for _, s := range c.shards {
+		var evicted []evictedData
+		now := c.now().UnixNano()
+		s.mu.Lock()
+		for key, data := range s.data {
+			if ok, _ := path.Match(pattern, key); !ok {
+				continue
+			}
+			s.remove(key)
+			c.logDelete(key)
+			if data.isExpiredAt(now) {
+				expired++
+				c.addEvicted(&evicted, data, EVICT_EXPIRED)
+				continue
+			}
+			removed++
+			c.addEvicted(&evicted, data, EVICT_DELETED)
+		}
+		s.mu.Unlock()
+		c.notifyEvicted(&evicted)
+	}
+	// The expired matches are deleted too, the expirations only count CleanExpired
+	atomic.AddUint64(&c.counters.deletes, uint64(removed+expired))
+	return removed, nil
+}
+
+// Keys returns the keys of the live data in the default cache
+func Keys() []string {
+	return defaultCache.Keys()
+}
+
+// Len returns the number of live data in the default cache
+func Len() int {
+	return defaultCache.Len()
+}
+
+// Range calls fn for each live data in the default cache until fn returns false
+func Range(fn func(key string, value []byte) bool) {
+	defaultCache.Range(fn)
+}
+
+// ScanPrefix returns the live data in the default cache whose keys start with the prefix
+func ScanPrefix(prefix string) map[string][]byte {
+	return defaultCache.ScanPrefix(prefix)
+}
+
+// DeleteMatching deletes the data in the default cache whose keys match the glob pattern
+func DeleteMatching(pattern string) (int, error) {
+	return defaultCache.DeleteMatching(pattern)
+}
@@ -0,0 +1,10 @@
+//go:build go1.23
+
+package mem
+
+import "iter"
+
+// All returns an iterator over the live data, see Range
+func (c *Cache) All() iter.Seq2[string, []byte] {
+	return c.Range
+}
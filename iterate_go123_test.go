@@ -0,0 +1,21 @@
+//go:build go1.23
+
+package mem
+
+import "testing"
+
+func TestAll(t *testing.T) {
+	t.Parallel()
+
+	c := iterateCache()
+	n := 0
+	for key, value := range c.All() {
+		if string(value) != key {
+			t.Errorf("%s: got %q", key, value)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Errorf("All yielded %d entries, want 5", n)
+	}
+}
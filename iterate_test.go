@@ -0,0 +1,116 @@
+package mem
+
+import (
+	"path"
+	"sort"
+	"testing"
+	"time"
+)
+
+// iterateCache returns a cache with live data of users and sessions, and expired data
+func iterateCache() *Cache {
+	c := NewCache(WithShards(4))
+	for _, key := range []string{"user:1", "user:2", "user:3", "session:1", "session:2"} {
+		c.Set(&MemData{Key: key, Value: []byte(key)})
+	}
+	c.Set(&MemData{Key: "user:old", Value: []byte("old"), Expire: time.Now().Add(-time.Hour).Unix()})
+	return c
+}
+
+func TestKeysAndLen(t *testing.T) {
+	t.Parallel()
+
+	c := iterateCache()
+	keys := c.Keys()
+	sort.Strings(keys)
+	want := []string{"session:1", "session:2", "user:1", "user:2", "user:3"}
+	if len(keys) != len(want) {
+		t.Fatalf("Keys: got %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("Keys: got %v, want %v", keys, want)
+		}
+	}
+	if n := c.Len(); n != 5 {
+		t.Errorf("Len: got %d, want 5", n)
+	}
+}
+
+func TestRange(t *testing.T) {
+	t.Parallel()
+
+	c := iterateCache()
+	seen := make(map[string]bool)
+	c.Range(func(key string, value []byte) bool {
+		if string(value) != key {
+			t.Errorf("%s: got %q", key, value)
+		}
+		seen[key] = true
+
+		// The cache can be used from the callback
+		c.Delete(key)
+		return true
+	})
+	if len(seen) != 5 || seen["user:old"] {
+		t.Errorf("Range visited %v", seen)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("got %d entries after deleting all of them, want 0", n)
+	}
+
+	// Returning false stops the iteration
+	c = iterateCache()
+	calls := 0
+	c.Range(func(key string, value []byte) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Range called fn %d times after it returned false", calls)
+	}
+}
+
+func TestScanPrefix(t *testing.T) {
+	t.Parallel()
+
+	c := iterateCache()
+	data := c.ScanPrefix("user:")
+	if len(data) != 3 {
+		t.Errorf("ScanPrefix: got %d entries, want 3", len(data))
+	}
+	if v := data["user:2"]; string(v) != "user:2" {
+		t.Errorf("user:2: got %q", v)
+	}
+	if _, ok := data["user:old"]; ok {
+		t.Error("ScanPrefix returned expired data")
+	}
+}
+
+func TestDeleteMatching(t *testing.T) {
+	t.Parallel()
+
+	c := iterateCache()
+	n, err := c.DeleteMatching("user:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("DeleteMatching: deleted %d, want 3", n)
+	}
+	if keys := c.Keys(); len(keys) != 2 {
+		t.Errorf("Keys after DeleteMatching: %v", keys)
+	}
+	if _, ok := c.shardFor("user:old").data["user:old"]; ok {
+		t.Error("DeleteMatching left the expired match")
+	}
+	if stats := c.Stats(); stats.Deletes != 4 || stats.Expirations != 0 {
+		t.Errorf("got %d deletes and %d expirations, want 4 and 0", stats.Deletes, stats.Expirations)
+	}
+	if _, err := c.DeleteMatching("session:["); err != path.ErrBadPattern {
+		t.Errorf("DeleteMatching of a bad pattern: got %v", err)
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("a bad pattern deleted data, %d entries left", n)
+	}
+}
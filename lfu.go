@@ -0,0 +1,93 @@
+package mem
+
+import "container/list"
+
+// lfuBucket holds the keys that have been used the same number of times
+type lfuBucket struct {
+	freq  int        // number of times the keys were used
+	items *list.List // keys in the bucket, front is the most recently used
+}
+
+// lfuItem is the position of a key in the frequency buckets
+type lfuItem struct {
+	key    string
+	bucket *list.Element // element of the bucket in lfuPolicy.buckets
+	elem   *list.Element // element of the key in the bucket items
+}
+
+// lfuPolicy evicts the least frequently used key, ties are broken by recency
+type lfuPolicy struct {
+	buckets *list.List          // buckets ordered by increasing frequency
+	items   map[string]*lfuItem // key to item lookup
+}
+
+// NewLFUPolicy returns a least frequently used eviction policy
+func NewLFUPolicy(capacity int) EvictionPolicy {
+	return &lfuPolicy{
+		buckets: list.New(),
+		items:   make(map[string]*lfuItem),
+	}
+}
+
+// OnInsert records a newly stored key with a frequency of one
+func (p *lfuPolicy) OnInsert(key string) {
+	if _, ok := p.items[key]; ok {
+		p.OnAccess(key)
+		return
+	}
+
+	// New keys always go to the bucket with the frequency of one
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = p.buckets.PushFront(&lfuBucket{freq: 1, items: list.New()})
+	}
+	item := &lfuItem{key: key, bucket: front}
+	item.elem = front.Value.(*lfuBucket).items.PushFront(item)
+	p.items[key] = item
+}
+
+// OnAccess moves the key to the bucket of the next frequency
+func (p *lfuPolicy) OnAccess(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	cur := item.bucket
+	curBucket := cur.Value.(*lfuBucket)
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != curBucket.freq+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{freq: curBucket.freq + 1, items: list.New()}, cur)
+	}
+
+	curBucket.items.Remove(item.elem)
+	if curBucket.items.Len() == 0 {
+		p.buckets.Remove(cur)
+	}
+	item.bucket = next
+	item.elem = next.Value.(*lfuBucket).items.PushFront(item)
+}
+
+// OnRemove forgets the key
+func (p *lfuPolicy) OnRemove(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	bucket := item.bucket.Value.(*lfuBucket)
+	bucket.items.Remove(item.elem)
+	if bucket.items.Len() == 0 {
+		p.buckets.Remove(item.bucket)
+	}
+	delete(p.items, key)
+}
+
+// Victim returns the least recently used key of the lowest frequency
+func (p *lfuPolicy) Victim() (string, bool) {
+	front := p.buckets.Front()
+	if front == nil {
+		return "", false
+	}
+	return front.Value.(*lfuBucket).items.Back().Value.(*lfuItem).key, true
+}
@@ -0,0 +1,156 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Loader loads the data of a key that is missing from the cache, it returns the
+// value and how long it may be cached, a TTL of 0 means the value never expires
+// and a negative TTL means the value is returned without being cached
+type Loader func(ctx context.Context) ([]byte, time.Duration, error)
+
+// loadCall is an in-flight or completed Loader call
+type loadCall struct {
+	done  chan struct{} // closed when the loader returned
+	value []byte        // value returned by the loader
+	err   error         // error returned by the loader
+}
+
+// negativeEntry is a cached Loader error
+type negativeEntry struct {
+	err    error // error returned by the loader
+	expire int64 // unix nano timestamp of when the error is forgotten
+}
+
+// WithNegativeTTL caches the errors returned by the GetOrLoad loader for the
+// given duration, so a failing backend is not called again for every request
+func WithNegativeTTL(ttl time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// GetOrLoad gets the data from the cache, on a miss it calls the loader and
+// stores the loaded value with its TTL. Concurrent misses for the same key
+// share a single loader call, which runs with the context of the first caller.
+// If that caller gives up, the waiters whose context is still alive load the key
+// again, and the errors of a context are never cached. A value set in the cache
+// while the loader runs is kept and returned instead of the loaded one.
+func (c *Cache) GetOrLoad(ctx context.Context, key string, loader Loader) ([]byte, error) {
+	for {
+		if v, ok := c.Get(key); ok {
+			return v, nil
+		}
+
+		c.loadMu.Lock()
+
+		// Return the cached loader error, if any
+		if e, ok := c.negative[key]; ok {
+			if e.expire > c.now().UnixNano() {
+				c.loadMu.Unlock()
+				return nil, e.err
+			}
+			delete(c.negative, key)
+		}
+
+		call, ok := c.calls[key]
+		if !ok {
+			call = &loadCall{done: make(chan struct{})}
+			c.calls[key] = call
+			c.loadMu.Unlock()
+			return c.load(ctx, key, loader, call)
+		}
+		c.loadMu.Unlock()
+
+		// Wait for the loader call of another caller
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !isContextError(call.err) || ctx.Err() != nil {
+			return call.value, call.err
+		}
+	}
+}
+
+// load calls the loader for the key and stores the value, the call is registered
+// in c.calls and its waiters are released when load returns
+func (c *Cache) load(ctx context.Context, key string, loader Loader, call *loadCall) ([]byte, error) {
+	// Release the waiters even if the loader panics, only the errors returned by
+	// the loader are cached
+	var loaderErr error
+	defer func() {
+		c.loadMu.Lock()
+		delete(c.calls, key)
+		if loaderErr != nil && c.negativeTTL > 0 && !isContextError(loaderErr) {
+			c.negative[key] = &negativeEntry{err: loaderErr, expire: c.now().Add(c.negativeTTL).UnixNano()}
+		}
+		c.loadMu.Unlock()
+		close(call.done)
+	}()
+
+	call.err = fmt.Errorf("loader panicked for key: %s", key)
+	value, ttl, err := loader(ctx)
+	if err != nil {
+		call.err, loaderErr = err, err
+		return nil, err
+	}
+	if ttl < 0 {
+		call.value, call.err = value, nil
+		return value, nil
+	}
+
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Keep the data set while the loader ran, it is newer than the loaded value
+	if data, ok := s.data[key]; ok && !data.isExpiredAt(c.now().UnixNano()) {
+		call.value, call.err = data.Value, nil
+		return data.Value, nil
+	}
+
+	// Store the loaded value, replacing the expired data if any
+	m := &MemData{Key: key, Value: value}
+	m.setTTL(c.now(), ttl)
+	if err := c.insertLocked(s, m, c.now().Unix(), &evicted); err != nil {
+		call.err = err
+		return nil, err
+	}
+	call.value, call.err = value, nil
+	atomic.AddUint64(&c.counters.sets, 1)
+	return value, nil
+}
+
+// isContextError returns true if the error comes from a canceled or expired context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// cleanNegative removes the expired loader errors
+func (c *Cache) cleanNegative() {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	now := c.now().UnixNano()
+	for k, e := range c.negative {
+		if e.expire <= now {
+			delete(c.negative, k)
+		}
+	}
+}
+
+// clearNegative removes all the loader errors
+func (c *Cache) clearNegative() {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	c.negative = make(map[string]*negativeEntry)
+}
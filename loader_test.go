@@ -0,0 +1,210 @@
+package mem
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/itrepablik/mem/memtest"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	c := NewCache()
+	ctx := context.Background()
+
+	var calls int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) ([]byte, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte("value"), time.Minute, nil
+	}
+
+	// Concurrent misses for the same key share one loader call
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(ctx, "key", loader)
+			if err != nil || string(v) != "value" {
+				t.Errorf("GetOrLoad: got %q, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+
+	// The loaded value is stored with its TTL
+	s := c.shardFor("key")
+	if data, ok := s.data["key"]; !ok || data.Expire < time.Now().Add(time.Second*59).Unix() {
+		t.Errorf("loaded value was not stored with its TTL: %+v", data)
+	}
+	if _, err := c.GetOrLoad(ctx, "key", loader); err != nil || atomic.LoadInt32(&calls) != 1 {
+		t.Error("GetOrLoad did not use the cached value")
+	}
+}
+
+func TestGetOrLoadReplacesExpired(t *testing.T) {
+	c := NewCache()
+	c.Set(&MemData{Key: "key", Value: []byte("old"), Expire: time.Now().Add(-time.Hour).Unix()})
+
+	v, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+		return []byte("new"), 0, nil
+	})
+	if err != nil || string(v) != "new" {
+		t.Fatalf("GetOrLoad: got %q, %v", v, err)
+	}
+	if v, ok := c.Get("key"); !ok || string(v) != "new" {
+		t.Errorf("Get: got %q, %v", v, ok)
+	}
+}
+
+func TestGetOrLoadNegativeTTL(t *testing.T) {
+	errBackend := errors.New("backend down")
+
+	var calls int32
+	loader := func(ctx context.Context) ([]byte, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, 0, errBackend
+	}
+
+	// Without a negative TTL every miss calls the loader
+	c := NewCache()
+	c.GetOrLoad(context.Background(), "key", loader)
+	c.GetOrLoad(context.Background(), "key", loader)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("loader called %d times, want 2", n)
+	}
+
+	// With a negative TTL the error is returned from the cache until it expires
+	atomic.StoreInt32(&calls, 0)
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	c = NewCache(WithNegativeTTL(100*time.Millisecond), WithClock(clk))
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetOrLoad(context.Background(), "key", loader); !errors.Is(err, errBackend) {
+			t.Errorf("GetOrLoad: got %v, want %v", err, errBackend)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+
+	clk.Advance(150 * time.Millisecond)
+	c.GetOrLoad(context.Background(), "key", loader)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("loader called %d times after the negative TTL, want 2", n)
+	}
+}
+
+func TestGetOrLoadWaiterContext(t *testing.T) {
+	c := NewCache()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	go c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+		close(started)
+		<-release
+		return []byte("value"), 0, nil
+	})
+	<-started
+
+	// A waiter gives up when its own context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.GetOrLoad(ctx, "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+		t.Error("second loader must not be called")
+		return nil, 0, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetOrLoad: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestGetOrLoadFirstCallerCanceled(t *testing.T) {
+	c := NewCache(WithNegativeTTL(time.Minute))
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+
+	go c.GetOrLoad(ctx, "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, 0, ctx.Err()
+	})
+	<-started
+
+	// The waiter loads the key again with its own context when the first caller gives up
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	v, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+		return []byte("value"), 0, nil
+	})
+	if err != nil || string(v) != "value" {
+		t.Fatalf("GetOrLoad: got %q, %v", v, err)
+	}
+
+	// The context error is not cached
+	if _, ok := c.negative["key"]; ok {
+		t.Error("the context error was cached")
+	}
+}
+
+func TestGetOrLoadKeepsNewerValue(t *testing.T) {
+	c := NewCache()
+	v, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+		c.Set(&MemData{Key: "key", Value: []byte("newer")})
+		return []byte("loaded"), 0, nil
+	})
+	if err != nil || string(v) != "newer" {
+		t.Errorf("GetOrLoad: got %q, %v, want the newer value", v, err)
+	}
+	if v, ok := c.Get("key"); !ok || string(v) != "newer" {
+		t.Errorf("Get: got %q, %v, want the newer value", v, ok)
+	}
+}
+
+func TestGetOrLoadPanicNotCached(t *testing.T) {
+	c := NewCache(WithNegativeTTL(time.Hour))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the loader panic was not propagated")
+			}
+		}()
+		c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+			panic("backend bug")
+		})
+	}()
+
+	// The next call runs its loader instead of returning the panic
+	v, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+		return []byte("value"), 0, nil
+	})
+	if err != nil || string(v) != "value" {
+		t.Errorf("GetOrLoad after a panic: got %q, %v", v, err)
+	}
+}
+
+func TestGetOrLoadNegativeLoaderTTL(t *testing.T) {
+	c := NewCache()
+	v, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) ([]byte, time.Duration, error) {
+		return []byte("value"), -1, nil
+	})
+	if err != nil || string(v) != "value" {
+		t.Fatalf("GetOrLoad: got %q, %v", v, err)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("got %d entries, want the value not to be cached", n)
+	}
+}
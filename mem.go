@@ -3,131 +3,667 @@ package mem
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var c *Cache
+// defaultCache is the cache used by the package-level functions
+var defaultCache = NewCache()
+
+// DEFAULT_TTL is the expiry duration used when no valid expiry option is given
+const DEFAULT_TTL = time.Minute * 30
 
 // MemData is a struct that holds the data for the memory
 type MemData struct {
-	Key     string // key for the data
-	Value   []byte // data to be stored in memory
-	Expire  int64  // unix timestamp, 0 means never expire
-	Created int64  // unix timestamp, the time the data stored in memory
+	Key     string   // key for the data
+	Value   []byte   // data to be stored in memory
+	Expire  int64    // unix timestamp, 0 means never expire
+	Created int64    // unix timestamp, the time the data stored in memory
+	Version uint64   // version set by the cache, it changes on every write of the data
+	Tags    []string // tags to invalidate the data by as a group with InvalidateTag
+
+	expireAt    int64         // unix nano timestamp set by the TTL methods, takes precedence over Expire
+	sliding     time.Duration // idle time after which sliding data expires, 0 means a fixed expiry
+	maxExpireAt int64         // unix nano timestamp the sliding expiry can never pass, 0 means no limit
 }
 
-// IsExpired returns true if the data is expired
+// IsExpired returns true if the data is expired by the real time, the cache
+// checks the expiry against its own clock, see WithClock
 func (m *MemData) IsExpired() bool {
-	if m.Expire == 0 {
+	return m.isExpiredAt(time.Now().UnixNano())
+}
+
+// deadline returns the unix nano timestamp from which the data is expired, 0 means never expire
+func (m *MemData) deadline() int64 {
+	switch {
+	case m.expireAt != 0:
+		return m.expireAt
+	case m.Expire != 0:
+		// Expire is in whole seconds, the data is still valid during that second
+		return (m.Expire + 1) * int64(time.Second)
+	}
+	return 0
+}
+
+// isExpiredAt returns true if the data is expired at the unix nano timestamp
+func (m *MemData) isExpiredAt(now int64) bool {
+	deadline := m.deadline()
+	return deadline != 0 && deadline <= now
+}
+
+// setSliding makes the data expire after it was not read for the idle duration,
+// but never later than maxLifetime from now, a maxLifetime of 0 means no limit
+func (m *MemData) setSliding(now time.Time, idle, maxLifetime time.Duration) {
+	// Without an idle duration it is a fixed expiry of maxLifetime
+	if idle <= 0 {
+		m.setTTL(now, maxLifetime)
+		return
+	}
+
+	m.sliding, m.maxExpireAt = idle, 0
+	if maxLifetime > 0 {
+		m.maxExpireAt = now.Add(maxLifetime).UnixNano()
+	}
+	m.renew(now)
+}
+
+// renew pushes the expiry of sliding data forward by its idle duration from now
+func (m *MemData) renew(now time.Time) {
+	if m.sliding <= 0 {
+		return
+	}
+
+	expireAt := now.Add(m.sliding).UnixNano()
+	if m.maxExpireAt != 0 && expireAt > m.maxExpireAt {
+		expireAt = m.maxExpireAt
+	}
+	m.expireAt = expireAt
+	m.Expire = time.Unix(0, expireAt).Unix()
+}
+
+// copyExpiry copies the expiry settings of the other data
+func (m *MemData) copyExpiry(other *MemData) {
+	m.Expire = other.Expire
+	m.expireAt = other.expireAt
+	m.sliding = other.sliding
+	m.maxExpireAt = other.maxExpireAt
+}
+
+// setTTL sets the expiry of the data to ttl from now, 0 means never expire
+func (m *MemData) setTTL(now time.Time, ttl time.Duration) {
+	m.Expire, m.expireAt, m.sliding, m.maxExpireAt = 0, 0, 0, 0
+	if ttl > 0 {
+		expireAt := now.Add(ttl)
+		m.Expire, m.expireAt = expireAt.Unix(), expireAt.UnixNano()
+	}
+}
+
+// isExpired returns true if the unix timestamp has passed, 0 means never expire
+func isExpired(expire int64) bool {
+	if expire == 0 {
 		return false
 	}
-	return m.Expire < time.Now().Local().Unix()
+	return expire < time.Now().Local().Unix()
 }
 
 // Cache is a struct that holds the data for the cache
 type Cache struct {
-	data map[string]*MemData // map of the data
-	mu   *sync.RWMutex       // read-write mutex, multiple readers, single writer
+	// The atomic 64-bit fields come first, so they are 64-bit aligned on 32-bit platforms
+	counters cacheCounters // atomic counters for the statistics
+	version  uint64        // last version given to written data
+	usage    cacheUsage    // entries and size of all the shards
+
+	shards     []*shard   // the data split by key hash, each with its own lock
+	shardCount int        // number of shards
+	maxEntries int        // maximum number of entries, 0 means unlimited
+	maxBytes   int64      // maximum total size of the keys and values, 0 means unlimited
+	newPolicy  PolicyFunc // constructor of the eviction policy
+	hooks      evictHooks // callbacks for the removed data
+	wlog       *WriteLog  // append-only log of the changes, nil when disabled
+	clock      Clock      // tells the time for the expiry of the data
+
+	loadMu      sync.Mutex                // guards calls and negative
+	calls       map[string]*loadCall      // in-flight GetOrLoad loader calls by key
+	negative    map[string]*negativeEntry // cached GetOrLoad loader errors by key
+	negativeTTL time.Duration             // how long loader errors are cached, 0 disables it
+}
+
+// CacheOption is an option for the cache
+type CacheOption func(*Cache)
+
+// WithMaxEntries limits the number of entries in the cache, when the cache is full
+// an entry chosen by the eviction policy is evicted to make room for the new one
+func WithMaxEntries(maxEntries int) CacheOption {
+	return func(c *Cache) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// WithMaxBytes limits the total size of the keys and values in the cache, entries
+// chosen by the eviction policy are evicted to stay within the memory budget
+func WithMaxBytes(maxBytes int64) CacheOption {
+	return func(c *Cache) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithEvictionPolicy sets the eviction policy used when the cache is full, the
+// built-in policies are NewLRUPolicy (default), NewLFUPolicy, NewFIFOPolicy,
+// NewARCPolicy and NewTinyLFUPolicy, any other PolicyFunc can be used as well
+func WithEvictionPolicy(newPolicy PolicyFunc) CacheOption {
+	return func(c *Cache) {
+		c.newPolicy = newPolicy
+	}
+}
+
+// WithShards splits the cache into the given number of shards, each with its own
+// lock, to reduce the lock contention between goroutines. The WithMaxEntries and
+// WithMaxBytes limits apply to the whole cache, a full cache evicts from the shard
+// of the new entry first and then from the other shards. While other goroutines
+// hold the locks of those shards, the limits can be exceeded until the next write.
+func WithShards(shardCount int) CacheOption {
+	return func(c *Cache) {
+		c.shardCount = shardCount
+	}
 }
 
 // NewCache returns a new cache
-func NewCache() *Cache {
-	return &Cache{
-		data: make(map[string]*MemData),
-		mu:   &sync.RWMutex{}, // read-write mutex, multiple readers, single writer
+func NewCache(opts ...CacheOption) *Cache {
+	c := &Cache{
+		shardCount: 1,
+		newPolicy:  NewLRUPolicy,
+		calls:      make(map[string]*loadCall),
+		negative:   make(map[string]*negativeEntry),
+	}
+
+	// Apply the options
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.shardCount <= 0 {
+		c.shardCount = 1
 	}
+	if c.clock == nil {
+		c.clock = realClock{}
+	}
+
+	// The eviction policies are only needed when the cache is bounded, each is
+	// sized for the share of the entries its shard holds on average
+	var newPolicy PolicyFunc
+	if c.maxEntries > 0 || c.maxBytes > 0 {
+		newPolicy = c.newPolicy
+	}
+	capacity := (c.maxEntries + c.shardCount - 1) / c.shardCount
+	c.shards = make([]*shard, c.shardCount)
+	for i := range c.shards {
+		c.shards[i] = newShard(capacity, &c.usage, newPolicy)
+	}
+
+	// Replay the write log before recording the new changes in it
+	if wlog := c.wlog; wlog != nil {
+		c.wlog = nil
+		wlog.replay(c)
+		c.wlog = wlog
+	}
+	return c
 }
 
 // Set sets the data in the cache
-func Set(m *MemData) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Cache) Set(m *MemData) error {
+	_, err := c.SetWithVersion(m)
+	return err
+}
+
+// SetWithVersion sets the data in the cache and returns its version for CompareAndSwap
+func (c *Cache) SetWithVersion(m *MemData) (uint64, error) {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(m.Key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	// If key already exists, return error
-	if _, ok := c.data[m.Key]; ok {
-		return fmt.Errorf("key already exists: %s", m.Key)
+	if _, ok := s.data[m.Key]; ok {
+		return 0, fmt.Errorf("%w: %s", ErrKeyExists, m.Key)
 	}
 
-	c.data[m.Key] = &MemData{
-		Key:     m.Key,
-		Value:   m.Value,
-		Expire:  m.Expire,
-		Created: time.Now().Local().Unix(),
+	if err := c.insertLocked(s, m, c.now().Unix(), &evicted); err != nil {
+		return 0, err
 	}
-	return nil
+	atomic.AddUint64(&c.counters.sets, 1)
+	return s.data[m.Key].Version, nil
+}
+
+// SetWithTTL sets the data in the cache, expiring it after the ttl with sub-second
+// precision, a ttl of 0 means never expire
+func (c *Cache) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	m := &MemData{Key: key, Value: value}
+	m.setTTL(c.now(), ttl)
+	return c.Set(m)
+}
+
+// SetSliding sets the data in the cache, expiring it once it was not read for the
+// idle duration, every successful Get renews the expiry. A maxLifetime above 0
+// is an absolute limit from now that the renewals can never pass.
+func (c *Cache) SetSliding(key string, value []byte, idle, maxLifetime time.Duration) error {
+	m := &MemData{Key: key, Value: value}
+	m.setSliding(c.now(), idle, maxLifetime)
+	return c.Set(m)
 }
 
 // Get gets the data from the cache
-func Get(key string) ([]byte, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func (c *Cache) Get(key string) ([]byte, bool) {
+	value, _, ok := c.lookup(key)
+	return value, ok
+}
+
+// lookup returns the value and the version of the live data by the key
+func (c *Cache) lookup(key string) ([]byte, uint64, bool) {
+	s := c.shardFor(key)
+	now := c.now()
 
-	if data, ok := c.data[key]; ok && !data.IsExpired() {
-		return data.Value, true
+	// Unbounded shards only need the read lock, unless the expiry slides
+	if s.policy == nil {
+		s.mu.RLock()
+		data, ok := s.data[key]
+		if !ok || data.isExpiredAt(now.UnixNano()) {
+			s.mu.RUnlock()
+			atomic.AddUint64(&c.counters.misses, 1)
+			return nil, 0, false
+		}
+		if data.sliding == 0 {
+			value, version := data.Value, data.Version
+			s.mu.RUnlock()
+			atomic.AddUint64(&c.counters.hits, 1)
+			return value, version, true
+		}
+		s.mu.RUnlock()
 	}
-	return nil, false
+
+	// Updating the eviction policy or the sliding expiry requires the write lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.data[key]
+	if !ok || data.isExpiredAt(now.UnixNano()) {
+		atomic.AddUint64(&c.counters.misses, 1)
+		return nil, 0, false
+	}
+	if s.policy != nil {
+		s.policy.OnAccess(key)
+	}
+	data.renew(now)
+	atomic.AddUint64(&c.counters.hits, 1)
+	return data.Value, data.Version, true
 }
 
 // Replace replaces the data in the cache with the new data by the key
-func Replace(key string, m *MemData) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Cache) Replace(key string, m *MemData) error {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	// Get the data from the cache by the key
-	if data, ok := c.data[key]; ok {
-		// If the data is not expired, replace the data
-		if !data.IsExpired() {
-			data.Value = m.Value
-			data.Expire = m.Expire
-			return nil
-		}
+	data, ok := s.data[key]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	if data.isExpiredAt(c.now().UnixNano()) {
+		return fmt.Errorf("%w: %s", ErrExpired, key)
+	}
+	if err := c.replaceLocked(s, data, m, &evicted); err != nil {
+		return err
+	}
+	atomic.AddUint64(&c.counters.replaces, 1)
+	return nil
+}
+
+// Upsert sets the data in the cache whether the key exists or not, it reports
+// whether live data was replaced
+func (c *Cache) Upsert(m *MemData) (bool, error) {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(m.Key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.data[m.Key]
+	existed := ok && !old.isExpiredAt(c.now().UnixNano())
+	if err := c.insertLocked(s, m, c.now().Unix(), &evicted); err != nil {
+		return existed, err
+	}
+	atomic.AddUint64(&c.counters.sets, 1)
+	return existed, nil
+}
+
+// SetIfAbsent sets the data in the cache only if the key is missing or expired,
+// it reports whether live data existed, in which case nothing is stored
+func (c *Cache) SetIfAbsent(m *MemData) (bool, error) {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(m.Key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.data[m.Key]; ok && !old.isExpiredAt(c.now().UnixNano()) {
+		return true, nil
+	}
+	if err := c.insertLocked(s, m, c.now().Unix(), &evicted); err != nil {
+		return false, err
 	}
-	return fmt.Errorf("key not found: %s", key)
+	atomic.AddUint64(&c.counters.sets, 1)
+	return false, nil
+}
+
+// ReplaceIfPresent replaces the data in the cache only if live data exists by the
+// key, it reports whether live data existed, a missing key is not an error
+func (c *Cache) ReplaceIfPresent(key string, m *MemData) (bool, error) {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.data[key]
+	if !ok || data.isExpiredAt(c.now().UnixNano()) {
+		return false, nil
+	}
+	if err := c.replaceLocked(s, data, m, &evicted); err != nil {
+		return true, err
+	}
+	atomic.AddUint64(&c.counters.replaces, 1)
+	return true, nil
 }
 
 // Delete deletes the data from the cache
-func Delete(key string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	delete(c.data, key)
+func (c *Cache) Delete(key string) {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if data, ok := s.remove(key); ok {
+		c.logDelete(key)
+		atomic.AddUint64(&c.counters.deletes, 1)
+		c.addEvicted(&evicted, data, EVICT_DELETED)
+	}
 }
 
-// ClearAll clears the cache
-func ClearAll() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.data = make(map[string]*MemData)
+// ClearAll clears all the shards of the cache at once
+func (c *Cache) ClearAll() {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	// Log the clear while holding all the shard locks, so no write can be
+	// logged after the clear and then be removed from the cache
+	for _, s := range c.shards {
+		s.mu.Lock()
+	}
+	c.logClear()
+	for _, s := range c.shards {
+		for _, data := range s.data {
+			c.addEvicted(&evicted, data, EVICT_CLEARED)
+		}
+		s.reset(c.newPolicy)
+		s.mu.Unlock()
+	}
+	c.clearNegative()
 }
 
-// CleanExpired cleans the expired cached data
-func CleanExpired(c *Cache) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// CleanExpired cleans the expired cached data, one shard at a time, it returns
+// the number of data removed
+func (c *Cache) CleanExpired() int {
+	removed := 0
+	for _, s := range c.shards {
+		var evicted []evictedData
+		now := c.now().UnixNano()
+		s.mu.Lock()
+		for k, v := range s.data {
+			if v.isExpiredAt(now) {
+				s.remove(k)
+				removed++
+				c.addEvicted(&evicted, v, EVICT_EXPIRED)
+			}
+		}
+		s.mu.Unlock()
+		c.notifyEvicted(&evicted)
+	}
+	c.cleanNegative()
+	atomic.AddUint64(&c.counters.expirations, uint64(removed))
+	return removed
+}
+
+// isFull returns true if adding the entries and bytes would exceed the limits of the cache
+func (c *Cache) isFull(entries int, bytes int64) bool {
+	if c.maxEntries > 0 && atomic.LoadInt64(&c.usage.entries)+int64(entries) > int64(c.maxEntries) {
+		return true
+	}
+	return c.maxBytes > 0 && atomic.LoadInt64(&c.usage.bytes)+bytes > c.maxBytes
+}
+
+// makeRoomLocked evicts entries until the entries and bytes fit in the limits of the
+// cache, first from the shard and then from the other shards, s.mu must be held
+func (c *Cache) makeRoomLocked(s *shard, entries int, bytes int64, evicted *[]evictedData) {
+	for c.isFull(entries, bytes) {
+		if !c.evictLocked(s, evicted) {
+			c.evictOthers(s, entries, bytes, evicted)
+			return
+		}
+	}
+}
+
+// evictOthers evicts entries from the shards other than s until the entries and bytes
+// fit in the limits of the cache. The shards locked by other goroutines are skipped,
+// waiting for them could deadlock with a goroutine waiting for s. s.mu must be held.
+func (c *Cache) evictOthers(s *shard, entries int, bytes int64, evicted *[]evictedData) {
+	for _, other := range c.shards {
+		if !c.isFull(entries, bytes) {
+			return
+		}
+		if other == s || !other.mu.TryLock() {
+			continue
+		}
+		for c.isFull(entries, bytes) {
+			if !c.evictLocked(other, evicted) {
+				break
+			}
+		}
+		other.mu.Unlock()
+	}
+}
+
+// evictLocked removes the entry chosen by the eviction policy of the shard, s.mu must be held
+func (c *Cache) evictLocked(s *shard, evicted *[]evictedData) bool {
+	key, ok := s.policy.Victim()
+	if !ok {
+		return false
+	}
+	data, ok := s.remove(key)
+	if !ok {
+		// The policy returned a key that is not stored, make it forget the key
+		s.policy.OnRemove(key)
+		return true
+	}
+	c.addEvicted(evicted, data, EVICT_CAPACITY)
+	atomic.AddUint64(&c.counters.evictions, 1)
+	return true
+}
+
+// replaceLocked replaces the value, the expiry and the tags of the stored data with the ones of m, s.mu must be held
+func (c *Cache) replaceLocked(s *shard, data *MemData, m *MemData, evicted *[]evictedData) error {
+	key := data.Key
+	size := entrySize(key, m.Value)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return fmt.Errorf("%w: %s is %d bytes, the budget is %d bytes", ErrTooLarge, key, size, c.maxBytes)
+	}
+
+	delta := size - entrySize(key, data.Value)
+	switch {
+	case s.policy == nil:
+		// The cache is unlimited
+	case c.isFull(0, delta):
+		// Take the entry out of the policy while evicting so it is never the victim
+		s.policy.OnRemove(key)
+		c.makeRoomLocked(s, 0, delta, evicted)
+		s.policy.OnInsert(key)
+	default:
+		s.policy.OnAccess(key)
+	}
+
+	c.addEvicted(evicted, data, EVICT_REPLACED)
+	s.untag(key, data.Tags)
+	data.Tags = uniqueTags(m.Tags)
+	s.tag(key, data.Tags)
+	data.Value = m.Value
+	data.Version = atomic.AddUint64(&c.version, 1)
+	data.copyExpiry(m)
+	s.addUsage(0, delta)
+	c.logSet(data)
+	return nil
+}
 
-	for k, v := range c.data {
-		if v.IsExpired() {
-			delete(c.data, k)
+// insertLocked stores a copy of the data created at the unix timestamp, replacing
+// any existing data by the key, s.mu must be held
+func (c *Cache) insertLocked(s *shard, m *MemData, created int64, evicted *[]evictedData) error {
+	size := entrySize(m.Key, m.Value)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return fmt.Errorf("%w: %s is %d bytes, the budget is %d bytes", ErrTooLarge, m.Key, size, c.maxBytes)
+	}
+	if old, ok := s.remove(m.Key); ok {
+		reason := EVICT_REPLACED
+		if old.isExpiredAt(c.now().UnixNano()) {
+			reason = EVICT_EXPIRED
 		}
+		c.addEvicted(evicted, old, reason)
+	}
+
+	// Make room for the new entry when the cache is full
+	if s.policy != nil {
+		c.makeRoomLocked(s, 1, size, evicted)
+		s.policy.OnInsert(m.Key)
 	}
+
+	data := &MemData{
+		Key:     m.Key,
+		Value:   m.Value,
+		Created: created,
+		Version: atomic.AddUint64(&c.version, 1),
+		Tags:    uniqueTags(m.Tags),
+	}
+	data.copyExpiry(m)
+	s.data[m.Key] = data
+	s.tag(m.Key, data.Tags)
+	s.addUsage(1, size)
+	c.logSet(data)
+	return nil
+}
+
+// entrySize returns the number of bytes the entry accounts for in the memory budget
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
+
+// Set sets the data in the default cache
+func Set(m *MemData) error {
+	return defaultCache.Set(m)
+}
+
+// SetWithTTL sets the data in the default cache, expiring it after the ttl
+func SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	return defaultCache.SetWithTTL(key, value, ttl)
+}
+
+// SetSliding sets the data in the default cache with a sliding expiry
+func SetSliding(key string, value []byte, idle, maxLifetime time.Duration) error {
+	return defaultCache.SetSliding(key, value, idle, maxLifetime)
 }
 
-// ExpiryTimeOpt is an option for the expiry time
+// Get gets the data from the default cache
+func Get(key string) ([]byte, bool) {
+	return defaultCache.Get(key)
+}
+
+// Replace replaces the data in the default cache with the new data by the key
+func Replace(key string, m *MemData) error {
+	return defaultCache.Replace(key, m)
+}
+
+// Upsert sets the data in the default cache whether the key exists or not
+func Upsert(m *MemData) (bool, error) {
+	return defaultCache.Upsert(m)
+}
+
+// SetIfAbsent sets the data in the default cache only if the key is missing or expired
+func SetIfAbsent(m *MemData) (bool, error) {
+	return defaultCache.SetIfAbsent(m)
+}
+
+// ReplaceIfPresent replaces the data in the default cache only if live data exists by the key
+func ReplaceIfPresent(key string, m *MemData) (bool, error) {
+	return defaultCache.ReplaceIfPresent(key, m)
+}
+
+// Delete deletes the data from the default cache
+func Delete(key string) {
+	defaultCache.Delete(key)
+}
+
+// ClearAll clears the default cache
+func ClearAll() {
+	defaultCache.ClearAll()
+}
+
+// CleanExpired cleans the expired cached data of the given cache, it returns the number of data removed
+func CleanExpired(c *Cache) int {
+	return c.CleanExpired()
+}
+
+// ExpiryTimeOpt is an option for the expiry time, it returns the unix timestamp
+// of timeIntervalValue times the interval from now, e.g. ExpiryTimeOpt(EVERY_MINUTE, 5)
 func ExpiryTimeOpt(timeInterval int, timeIntervalValue int) int64 {
+	return time.Now().Local().Add(ExpiryTTLOpt(timeInterval, timeIntervalValue)).Unix()
+}
+
+// ExpiryTTLOpt is an option for the TTL methods, it returns timeIntervalValue times
+// the interval, e.g. ExpiryTTLOpt(EVERY_MINUTE, 5) is 5 minutes
+func ExpiryTTLOpt(timeInterval int, timeIntervalValue int) time.Duration {
 	// Set default time interval value to 1
 	if timeIntervalValue <= 0 {
 		timeIntervalValue = 1
 	}
 
 	// Check if timeInterval is valid
-	switch timeInterval {
-	case EVERY_SECOND, EVERY_MINUTE, EVERY_HOUR:
-		return time.Now().Local().Add(time.Duration(timeInterval) * time.Duration(timeIntervalValue)).Unix()
-	default:
-		return time.Now().Local().Add(time.Minute * 30).Unix()
+	interval := intervalDuration(timeInterval)
+	if interval == 0 {
+		return DEFAULT_TTL
 	}
+	return interval * time.Duration(timeIntervalValue)
 }
 
 // DefaultExpiryTimeOpt is an option for the default expiry time
 func DefaultExpiryTimeOpt() int64 {
-	return time.Now().Local().Add(time.Minute * 30).Unix()
+	return time.Now().Local().Add(DEFAULT_TTL).Unix()
+}
+
+// intervalDuration returns the duration of the EVERY_SECOND, EVERY_MINUTE or
+// EVERY_HOUR interval, 0 for any other interval
+func intervalDuration(interval int) time.Duration {
+	switch interval {
+	case EVERY_SECOND:
+		return time.Second
+	case EVERY_MINUTE:
+		return time.Minute
+	case EVERY_HOUR:
+		return time.Hour
+	}
+	return 0
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/itrepablik/mem/memtest"
 )
 
 func initCache() (*Cache, *MemData) {
@@ -122,3 +124,213 @@ func TestClear(t *testing.T) {
 	}
 	t.Logf("Get: %s, %v", string(v), ok)
 }
+
+func TestCacheInstances(t *testing.T) {
+	t.Parallel()
+
+	// Two caches must not share data with each other
+	c1, c2 := NewCache(), NewCache()
+	if err := c1.Set(&MemData{Key: "key", Value: []byte("one")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c2.Set(&MemData{Key: "key", Value: []byte("two")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, ok := c1.Get("key"); !ok || string(v) != "one" {
+		t.Errorf("c1.Get: got %q, %v", v, ok)
+	}
+	if v, ok := c2.Get("key"); !ok || string(v) != "two" {
+		t.Errorf("c2.Get: got %q, %v", v, ok)
+	}
+
+	// Clearing one cache leaves the other untouched
+	c1.ClearAll()
+	if _, ok := c1.Get("key"); ok {
+		t.Error("c1.ClearAll failed")
+	}
+	if _, ok := c2.Get("key"); !ok {
+		t.Error("c2 was cleared by c1.ClearAll")
+	}
+}
+
+func TestCacheCleanExpired(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache()
+	c.Set(&MemData{Key: "old", Value: []byte("value"), Expire: time.Now().Add(-time.Hour).Unix()})
+	c.Set(&MemData{Key: "new", Value: []byte("value"), Expire: time.Now().Add(time.Hour).Unix()})
+
+	c.CleanExpired()
+	if _, ok := c.shards[0].data["old"]; ok {
+		t.Error("expired data was not cleaned")
+	}
+	if _, ok := c.shards[0].data["new"]; !ok {
+		t.Error("live data was cleaned")
+	}
+}
+
+func TestSetWithTTL(t *testing.T) {
+	t.Parallel()
+
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	c := NewCache(WithClock(clk))
+	if err := c.SetWithTTL("key", []byte("value"), 100*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetWithTTL("forever", []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.Get("key"); !ok || string(v) != "value" {
+		t.Errorf("Get: got %q, %v", v, ok)
+	}
+
+	// The data expires with sub-second precision
+	clk.Advance(150 * time.Millisecond)
+	if _, ok := c.Get("key"); ok {
+		t.Error("data did not expire after its TTL")
+	}
+	if _, ok := c.Get("forever"); !ok {
+		t.Error("data without a TTL expired")
+	}
+
+	c.CleanExpired()
+	if _, ok := c.shards[0].data["key"]; ok {
+		t.Error("expired data was not cleaned")
+	}
+}
+
+func TestExpiryTimeOpt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		interval, value int
+		want            time.Duration
+	}{
+		{EVERY_SECOND, 30, 30 * time.Second},
+		{EVERY_MINUTE, 5, 5 * time.Minute},
+		{EVERY_HOUR, 2, 2 * time.Hour},
+		{EVERY_HOUR, 0, time.Hour},
+		{0, 5, DEFAULT_TTL},
+	}
+	for _, tt := range tests {
+		if got := ExpiryTTLOpt(tt.interval, tt.value); got != tt.want {
+			t.Errorf("ExpiryTTLOpt(%d, %d): got %s, want %s", tt.interval, tt.value, got, tt.want)
+		}
+
+		want := time.Now().Add(tt.want).Unix()
+		if got := ExpiryTimeOpt(tt.interval, tt.value); got < want-1 || got > want+1 {
+			t.Errorf("ExpiryTimeOpt(%d, %d): got %d, want %d", tt.interval, tt.value, got, want)
+		}
+	}
+}
+
+func TestSetSliding(t *testing.T) {
+	t.Parallel()
+
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	c := NewCache(WithClock(clk))
+	if err := c.SetSliding("key", []byte("value"), 100*time.Millisecond, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every Get pushes the expiry forward, so the data outlives its idle duration
+	for i := 0; i < 4; i++ {
+		clk.Advance(50 * time.Millisecond)
+		if _, ok := c.Get("key"); !ok {
+			t.Fatalf("sliding data expired after %d reads", i)
+		}
+	}
+
+	// Without reads the data expires
+	clk.Advance(150 * time.Millisecond)
+	if _, ok := c.Get("key"); ok {
+		t.Error("sliding data did not expire when idle")
+	}
+}
+
+func TestSetSlidingMaxLifetime(t *testing.T) {
+	t.Parallel()
+
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	c := NewCache(WithMaxEntries(10), WithClock(clk))
+	if err := c.SetSliding("key", []byte("value"), 100*time.Millisecond, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// The renewals can never pass the maximum lifetime
+	for i := 0; i < 9; i++ {
+		clk.Advance(20 * time.Millisecond)
+		if _, ok := c.Get("key"); !ok {
+			t.Fatalf("sliding data expired before its maximum lifetime, after %d reads", i)
+		}
+	}
+	clk.Advance(30 * time.Millisecond)
+	if _, ok := c.Get("key"); ok {
+		t.Error("sliding data outlived its maximum lifetime")
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache()
+	if existed, err := c.Upsert(&MemData{Key: "key", Value: []byte("value")}); err != nil || existed {
+		t.Errorf("Upsert of a new key: got %v, %v", existed, err)
+	}
+	if existed, err := c.Upsert(&MemData{Key: "key", Value: []byte("new value")}); err != nil || !existed {
+		t.Errorf("Upsert of an existing key: got %v, %v", existed, err)
+	}
+	if v, _ := c.Get("key"); string(v) != "new value" {
+		t.Errorf("Get: got %q", v)
+	}
+
+	// Expired data does not count as existing
+	c.Upsert(&MemData{Key: "old", Value: []byte("value"), Expire: time.Now().Add(-time.Hour).Unix()})
+	if existed, _ := c.Upsert(&MemData{Key: "old", Value: []byte("value")}); existed {
+		t.Error("Upsert reported expired data as existing")
+	}
+}
+
+func TestSetIfAbsent(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache()
+	c.Set(&MemData{Key: "key", Value: []byte("value")})
+	c.Set(&MemData{Key: "old", Value: []byte("value"), Expire: time.Now().Add(-time.Hour).Unix()})
+
+	if existed, err := c.SetIfAbsent(&MemData{Key: "key", Value: []byte("new value")}); err != nil || !existed {
+		t.Errorf("SetIfAbsent of a live key: got %v, %v", existed, err)
+	}
+	if v, _ := c.Get("key"); string(v) != "value" {
+		t.Errorf("SetIfAbsent overwrote live data: %q", v)
+	}
+
+	// Unlike Set, expired data that is not cleaned yet is treated as absent
+	if existed, err := c.SetIfAbsent(&MemData{Key: "old", Value: []byte("new value")}); err != nil || existed {
+		t.Errorf("SetIfAbsent of an expired key: got %v, %v", existed, err)
+	}
+	if v, ok := c.Get("old"); !ok || string(v) != "new value" {
+		t.Errorf("Get: got %q, %v", v, ok)
+	}
+}
+
+func TestReplaceIfPresent(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache()
+	if existed, err := c.ReplaceIfPresent("key", &MemData{Value: []byte("value")}); err != nil || existed {
+		t.Errorf("ReplaceIfPresent of a missing key: got %v, %v", existed, err)
+	}
+	if _, ok := c.Get("key"); ok {
+		t.Error("ReplaceIfPresent stored a missing key")
+	}
+
+	c.Set(&MemData{Key: "key", Value: []byte("value")})
+	if existed, err := c.ReplaceIfPresent("key", &MemData{Value: []byte("new value")}); err != nil || !existed {
+		t.Errorf("ReplaceIfPresent of a live key: got %v, %v", existed, err)
+	}
+	if v, _ := c.Get("key"); string(v) != "new value" {
+		t.Errorf("Get: got %q", v)
+	}
+}
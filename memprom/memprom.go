@@ -0,0 +1,160 @@
+// Package memprom exports the statistics of the mem caches and cleaners in the
+// Prometheus text exposition format, without depending on the Prometheus client.
+package memprom
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/itrepablik/mem"
+)
+
+// CONTENT_TYPE is the content type of the Prometheus text exposition format
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Exporter is an http.Handler that renders the statistics of the registered
+// caches and of all the running cleaners
+type Exporter struct {
+	caches map[string]*mem.Cache // registered caches by name
+	mu     sync.RWMutex          // guards caches
+}
+
+// metric is a single metric family with its samples
+type metric struct {
+	name    string
+	help    string
+	kind    string // counter or gauge
+	samples []sample
+}
+
+// sample is a single labeled value of a metric
+type sample struct {
+	labels string
+	value  string
+}
+
+// NewExporter returns a new exporter for the given caches by name
+func NewExporter(caches map[string]*mem.Cache) *Exporter {
+	e := &Exporter{caches: make(map[string]*mem.Cache)}
+	for name, c := range caches {
+		e.caches[name] = c
+	}
+	return e
+}
+
+// Register adds the cache to the exporter, its metrics are labeled with the name
+func (e *Exporter) Register(name string, c *mem.Cache) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.caches[name] = c
+}
+
+// Unregister removes the cache from the exporter
+func (e *Exporter) Unregister(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.caches, name)
+}
+
+// ServeHTTP renders the metrics
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	if err := e.WriteMetrics(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WriteMetrics writes the metrics in the Prometheus text exposition format
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range append(e.cacheMetrics(), cleanerMetrics()...) {
+		if len(m.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", m.name, m.kind)
+		for _, s := range m.samples {
+			fmt.Fprintf(bw, "%s{%s} %s\n", m.name, s.labels, s.value)
+		}
+	}
+	return bw.Flush()
+}
+
+// cacheMetrics returns the metrics of the registered caches
+func (e *Exporter) cacheMetrics() []*metric {
+	e.mu.RLock()
+	names := make([]string, 0, len(e.caches))
+	for name := range e.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stats := make([]mem.CacheStats, len(names))
+	for i, name := range names {
+		stats[i] = e.caches[name].Stats()
+	}
+	e.mu.RUnlock()
+
+	metrics := []*metric{
+		{name: "mem_cache_hits_total", help: "Get calls that found live data.", kind: "counter"},
+		{name: "mem_cache_misses_total", help: "Get calls that found no data or expired data.", kind: "counter"},
+		{name: "mem_cache_sets_total", help: "Data stored by the set methods.", kind: "counter"},
+		{name: "mem_cache_replaces_total", help: "Data replaced by Replace.", kind: "counter"},
+		{name: "mem_cache_deletes_total", help: "Data removed by Delete, DeleteMatching and InvalidateTag.", kind: "counter"},
+		{name: "mem_cache_expirations_total", help: "Expired data removed by CleanExpired.", kind: "counter"},
+		{name: "mem_cache_evictions_total", help: "Data evicted to stay within the cache limits.", kind: "counter"},
+		{name: "mem_cache_entries", help: "Number of entries currently stored.", kind: "gauge"},
+		{name: "mem_cache_bytes", help: "Total size of the keys and values currently stored.", kind: "gauge"},
+	}
+	for i, name := range names {
+		s := stats[i]
+		labels := fmt.Sprintf(`cache="%s"`, escapeLabel(name))
+		values := []string{
+			fmt.Sprint(s.Hits), fmt.Sprint(s.Misses), fmt.Sprint(s.Sets), fmt.Sprint(s.Replaces),
+			fmt.Sprint(s.Deletes), fmt.Sprint(s.Expirations), fmt.Sprint(s.Evictions),
+			fmt.Sprint(s.Entries), fmt.Sprint(s.Bytes),
+		}
+		for j, v := range values {
+			metrics[j].samples = append(metrics[j].samples, sample{labels: labels, value: v})
+		}
+	}
+	return metrics
+}
+
+// cleanerMetrics returns the run data of the cleaners in the mem.TS scheduler
+func cleanerMetrics() []*metric {
+	var cleaners []mem.Cleaner
+	for _, list := range mem.GetAllCleanerSchedules() {
+		cleaners = append(cleaners, list...)
+	}
+	sort.Slice(cleaners, func(i, j int) bool { return cleaners[i].TaskName < cleaners[j].TaskName })
+
+	metrics := []*metric{
+		{name: "mem_cleaner_last_run_timestamp_seconds", help: "Unix time of the last cleaner run, 0 if it never ran.", kind: "gauge"},
+		{name: "mem_cleaner_next_run_timestamp_seconds", help: "Unix time of the next cleaner run.", kind: "gauge"},
+		{name: "mem_cleaner_last_run_duration_seconds", help: "Duration of the last cleaner run.", kind: "gauge"},
+		{name: "mem_cleaner_last_run_removed", help: "Expired data removed by the last cleaner run.", kind: "gauge"},
+		{name: "mem_cleaner_runs_total", help: "Number of cleaner runs.", kind: "counter"},
+		{name: "mem_cleaner_removed_total", help: "Expired data removed by all the cleaner runs.", kind: "counter"},
+	}
+	for _, c := range cleaners {
+		labels := fmt.Sprintf(`task="%s"`, escapeLabel(c.TaskName))
+		values := []string{
+			fmt.Sprint(c.LastRun), fmt.Sprint(c.NextRun), fmt.Sprint(c.LastDuration.Seconds()),
+			fmt.Sprint(c.LastRemoved), fmt.Sprint(c.Runs), fmt.Sprint(c.TotalRemoved),
+		}
+		for j, v := range values {
+			metrics[j].samples = append(metrics[j].samples, sample{labels: labels, value: v})
+		}
+	}
+	return metrics
+}
+
+// escapeLabel escapes the label value as required by the exposition format
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,64 @@
+package memprom
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/itrepablik/mem"
+)
+
+func TestExporter(t *testing.T) {
+	c := mem.NewCache()
+	c.Set(&mem.MemData{Key: "key", Value: []byte("value")})
+	c.Get("key")
+	c.Get("missing")
+
+	e := NewExporter(map[string]*mem.Cache{"sessions": c})
+	e.Register(`we"ird`, mem.NewCache())
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != CONTENT_TYPE {
+		t.Errorf("Content-Type: got %q", ct)
+	}
+
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		"# TYPE mem_cache_hits_total counter\n",
+		`mem_cache_hits_total{cache="sessions"} 1` + "\n",
+		`mem_cache_misses_total{cache="sessions"} 1` + "\n",
+		`mem_cache_entries{cache="sessions"} 1` + "\n",
+		`mem_cache_bytes{cache="sessions"} 8` + "\n",
+		`mem_cache_entries{cache="we\"ird"} 0` + "\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+
+	e.Unregister("sessions")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(rec.Body.String(), "sessions") {
+		t.Error("unregistered cache is still exported")
+	}
+}
+
+func TestCleanerMetrics(t *testing.T) {
+	cleaner, err := mem.NewCleaner(mem.FREQUENTLY, mem.WithIntervalValue(mem.EVERY_HOUR, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleaner.AddCleaner()
+
+	var sb strings.Builder
+	if err := NewExporter(nil).WriteMetrics(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `mem_cleaner_runs_total{task="` + cleaner.TaskName + `"} 0` + "\n"
+	if !strings.Contains(sb.String(), want) {
+		t.Errorf("metrics do not contain %q:\n%s", want, sb.String())
+	}
+}
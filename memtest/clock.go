@@ -0,0 +1,92 @@
+// Package memtest provides helpers to test code that uses the mem cache and
+// cleaner without waiting for the real time to pass.
+package memtest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a clock that only moves when it is advanced, it satisfies the
+// mem.Clock interface. Use it with mem.WithClock and mem.WithCleanerClock.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer // waiting timers
+}
+
+// fakeTimer is a timer of the fake clock
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewFakeClock returns a fake clock set to the time
+func NewFakeClock(now time.Time) *FakeClock {
+	f := &FakeClock{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the time of the clock
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer returns the channel of a timer that fires once the clock is advanced
+// by d, and the function that stops it
+func (f *FakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t := &fakeTimer{at: f.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- f.now
+		return t.c, func() bool { return false }
+	}
+	f.timers = append(f.timers, t)
+	f.cond.Broadcast()
+	return t.c, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.removeTimer(t)
+	}
+}
+
+// Advance moves the clock forward by d and fires the timers that are due
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	for _, t := range append([]*fakeTimer(nil), f.timers...) {
+		if !t.at.After(f.now) {
+			f.removeTimer(t)
+			t.c <- f.now
+		}
+	}
+}
+
+// BlockUntil waits until n timers are waiting to fire, e.g. until a cleaner
+// finished its run and waits for the next one
+func (f *FakeClock) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.timers) < n {
+		f.cond.Wait()
+	}
+}
+
+// removeTimer removes the timer, false if it already fired or was stopped, f.mu must be held
+func (f *FakeClock) removeTimer(t *fakeTimer) bool {
+	for i, other := range f.timers {
+		if other == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package memtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFakeClock(start)
+
+	c, _ := f.NewTimer(time.Minute)
+	stopped, stop := f.NewTimer(time.Minute)
+	if !stop() {
+		t.Error("stop of a waiting timer returned false")
+	}
+	f.BlockUntil(1)
+
+	f.Advance(59 * time.Second)
+	select {
+	case <-c:
+		t.Fatal("the timer fired early")
+	default:
+	}
+
+	f.Advance(time.Second)
+	select {
+	case now := <-c:
+		if !now.Equal(start.Add(time.Minute)) {
+			t.Errorf("the timer fired at %s", now)
+		}
+	default:
+		t.Fatal("the timer did not fire")
+	}
+	select {
+	case <-stopped:
+		t.Error("the stopped timer fired")
+	default:
+	}
+	if stop() {
+		t.Error("stop of a stopped timer returned true")
+	}
+
+	// A timer that is already due fires at once
+	c, _ = f.NewTimer(0)
+	select {
+	case <-c:
+	default:
+		t.Error("the timer of 0 did not fire")
+	}
+}
@@ -0,0 +1,87 @@
+package mem
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EvictReason is the reason why data was removed from the cache
+type EvictReason int
+
+// Evict reasons, avoid 0 as it is the default
+const (
+	EVICT_EXPIRED  EvictReason = iota + 1 // removed by CleanExpired or overwritten after it expired
+	EVICT_DELETED                         // removed by Delete
+	EVICT_REPLACED                        // the value was replaced by a new one
+	EVICT_CLEARED                         // removed by ClearAll
+	EVICT_CAPACITY                        // evicted to stay within the cache limits
+)
+
+// String returns the name of the evict reason
+func (r EvictReason) String() string {
+	switch r {
+	case EVICT_EXPIRED:
+		return "expired"
+	case EVICT_DELETED:
+		return "deleted"
+	case EVICT_REPLACED:
+		return "replaced"
+	case EVICT_CLEARED:
+		return "cleared"
+	case EVICT_CAPACITY:
+		return "capacity"
+	}
+	return "unknown"
+}
+
+// EvictFunc is called with the key and the value of the data removed from the cache
+type EvictFunc func(key string, value []byte, reason EvictReason)
+
+// evictedData is data removed from the cache that is waiting for the EvictFunc calls
+type evictedData struct {
+	key    string
+	value  []byte
+	reason EvictReason
+}
+
+// evictHooks holds the registered EvictFunc callbacks
+type evictHooks struct {
+	mu    sync.RWMutex
+	funcs []EvictFunc
+	count int32 // number of funcs, read without the lock to skip the bookkeeping
+}
+
+// OnEvict registers a callback for every data removed from the cache, with the
+// reason of the removal. The callbacks run after the cache lock is released,
+// so they may safely use the cache.
+func (c *Cache) OnEvict(fn EvictFunc) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.funcs = append(c.hooks.funcs, fn)
+	atomic.StoreInt32(&c.hooks.count, int32(len(c.hooks.funcs)))
+}
+
+// addEvicted records the removed data for the callbacks, if there are any
+func (c *Cache) addEvicted(evicted *[]evictedData, data *MemData, reason EvictReason) {
+	if atomic.LoadInt32(&c.hooks.count) == 0 {
+		return
+	}
+	*evicted = append(*evicted, evictedData{key: data.Key, value: data.Value, reason: reason})
+}
+
+// notifyEvicted calls the callbacks for the removed data, the cache lock must not be held
+func (c *Cache) notifyEvicted(evicted *[]evictedData) {
+	if len(*evicted) == 0 {
+		return
+	}
+
+	c.hooks.mu.RLock()
+	funcs := c.hooks.funcs
+	c.hooks.mu.RUnlock()
+
+	for _, e := range *evicted {
+		for _, fn := range funcs {
+			fn(e.key, e.value, e.reason)
+		}
+	}
+}
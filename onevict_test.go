@@ -0,0 +1,73 @@
+package mem
+
+import (
+	"testing"
+	"time"
+)
+
+type evictEvent struct {
+	key, value string
+	reason     EvictReason
+}
+
+// recordEvictions registers a callback that records every removed data of the cache
+func recordEvictions(c *Cache) *[]evictEvent {
+	events := &[]evictEvent{}
+	c.OnEvict(func(key string, value []byte, reason EvictReason) {
+		*events = append(*events, evictEvent{key, string(value), reason})
+	})
+	return events
+}
+
+func TestOnEvict(t *testing.T) {
+	c := NewCache(WithMaxEntries(2))
+	events := recordEvictions(c)
+
+	c.Set(&MemData{Key: "key1", Value: []byte("value1")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value2")})
+	c.Replace("key2", &MemData{Value: []byte("new value2")})
+	c.Set(&MemData{Key: "key3", Value: []byte("value3")})
+	c.Delete("key2")
+	c.Set(&MemData{Key: "key4", Value: []byte("value4"), Expire: time.Now().Add(-time.Hour).Unix()})
+	c.CleanExpired()
+	c.ClearAll()
+
+	want := []evictEvent{
+		{"key2", "value2", EVICT_REPLACED},
+		{"key1", "value1", EVICT_CAPACITY},
+		{"key2", "new value2", EVICT_DELETED},
+		{"key4", "value4", EVICT_EXPIRED},
+		{"key3", "value3", EVICT_CLEARED},
+	}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d events %v, want %v", len(*events), *events, want)
+	}
+	for i, e := range *events {
+		if e != want[i] {
+			t.Errorf("event %d: got %v, want %v", i, e, want[i])
+		}
+	}
+}
+
+func TestOnEvictOutsideLock(t *testing.T) {
+	c := NewCache()
+
+	// The callback can use the cache without a deadlock
+	c.OnEvict(func(key string, value []byte, reason EvictReason) {
+		if reason == EVICT_DELETED {
+			c.Set(&MemData{Key: key + ":deleted", Value: value})
+		}
+	})
+
+	c.Set(&MemData{Key: "key", Value: []byte("value")})
+	c.Delete("key")
+	if v, ok := c.Get("key:deleted"); !ok || string(v) != "value" {
+		t.Errorf("Get: got %q, %v", v, ok)
+	}
+}
+
+func TestEvictReasonString(t *testing.T) {
+	if EVICT_CAPACITY.String() != "capacity" || EvictReason(0).String() != "unknown" {
+		t.Error("EvictReason.String failed")
+	}
+}
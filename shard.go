@@ -0,0 +1,110 @@
+package mem
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// shard is a part of the cache with its own lock, the keys are spread over
+// the shards by their hash so writers to different shards do not block each other
+type shard struct {
+	data     map[string]*MemData            // map of the data
+	mu       *sync.RWMutex                  // read-write mutex, multiple readers, single writer
+	capacity int                            // number of entries the eviction policy is sized for
+	size     int64                          // current total size of the keys and values
+	usage    *cacheUsage                    // entries and size of all the shards of the cache
+	policy   EvictionPolicy                 // eviction policy, nil when the cache is unlimited
+	tags     map[string]map[string]struct{} // keys of the data by their tags
+}
+
+// cacheUsage is the number of entries and the total size of the keys and values
+// of all the shards, updated atomically under the lock of the changed shard
+type cacheUsage struct {
+	entries int64
+	bytes   int64
+}
+
+// newShard returns a new empty shard, the policy is nil when newPolicy is nil
+func newShard(capacity int, usage *cacheUsage, newPolicy PolicyFunc) *shard {
+	s := &shard{
+		data:     make(map[string]*MemData),
+		tags:     make(map[string]map[string]struct{}),
+		mu:       &sync.RWMutex{},
+		capacity: capacity,
+		usage:    usage,
+	}
+	if newPolicy != nil {
+		s.policy = newPolicy(capacity)
+	}
+	return s
+}
+
+// shardFor returns the shard that holds the key
+func (c *Cache) shardFor(key string) *shard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+
+	// FNV-1a hash of the key
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
+
+// reset removes all the data from the shard, s.mu must be held
+func (s *shard) reset(newPolicy PolicyFunc) {
+	s.addUsage(-len(s.data), -s.size)
+	s.data = make(map[string]*MemData)
+	s.tags = make(map[string]map[string]struct{})
+	if s.policy != nil {
+		s.policy = newPolicy(s.capacity)
+	}
+}
+
+// addUsage adds the entries and bytes to the size of the shard and of the cache, s.mu must be held
+func (s *shard) addUsage(entries int, bytes int64) {
+	s.size += bytes
+	atomic.AddInt64(&s.usage.entries, int64(entries))
+	atomic.AddInt64(&s.usage.bytes, bytes)
+}
+
+// remove removes the data by the key and returns it, s.mu must be held
+func (s *shard) remove(key string) (*MemData, bool) {
+	data, ok := s.data[key]
+	if !ok {
+		return nil, false
+	}
+	delete(s.data, key)
+	s.untag(key, data.Tags)
+	s.addUsage(-1, -entrySize(key, data.Value))
+	if s.policy != nil {
+		s.policy.OnRemove(key)
+	}
+	return data, true
+}
+
+// tag adds the key to the index of each tag, s.mu must be held
+func (s *shard) tag(key string, tags []string) {
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// untag removes the key from the index of each tag, s.mu must be held
+func (s *shard) untag(key string, tags []string) {
+	for _, tag := range tags {
+		keys := s.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package mem
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestShards(t *testing.T) {
+	c := NewCache(WithShards(8))
+	for i := 0; i < 1000; i++ {
+		if err := c.Set(&MemData{Key: strconv.Itoa(i), Value: []byte("value")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The keys are spread over all the shards
+	total := 0
+	for i, s := range c.shards {
+		if len(s.data) == 0 {
+			t.Errorf("shard %d is empty", i)
+		}
+		total += len(s.data)
+	}
+	if total != 1000 {
+		t.Errorf("got %d entries, want 1000", total)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if _, ok := c.Get(strconv.Itoa(i)); !ok {
+			t.Fatalf("Get %d failed", i)
+		}
+	}
+
+	c.ClearAll()
+	for i, s := range c.shards {
+		if len(s.data) != 0 {
+			t.Errorf("shard %d was not cleared", i)
+		}
+	}
+}
+
+func TestShardsMaxEntries(t *testing.T) {
+	c := NewCache(WithShards(4), WithMaxEntries(100))
+	for i := 0; i < 1000; i++ {
+		c.Set(&MemData{Key: strconv.Itoa(i), Value: []byte("value")})
+	}
+
+	// The limit applies to the whole cache
+	total := 0
+	for _, s := range c.shards {
+		total += len(s.data)
+	}
+	if total != 100 || c.Evictions() != 900 {
+		t.Errorf("got %d entries and %d evictions, want 100 and 900", total, c.Evictions())
+	}
+}
+
+func TestShardsMoreThanMaxEntries(t *testing.T) {
+	c := NewCache(WithShards(16), WithMaxEntries(4))
+	for i := 0; i < 100; i++ {
+		c.Set(&MemData{Key: strconv.Itoa(i), Value: []byte("value")})
+	}
+	if stats := c.Stats(); stats.Entries != 4 || stats.Evictions != 96 {
+		t.Errorf("got %d entries and %d evictions, want 4 and 96", stats.Entries, stats.Evictions)
+	}
+}
+
+func TestShardsMaxBytes(t *testing.T) {
+	c := NewCache(WithShards(16), WithMaxBytes(1000))
+
+	// A value larger than the share of a shard fits in the budget of the cache
+	if err := c.Set(&MemData{Key: "big", Value: make([]byte, 101)}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		c.Set(&MemData{Key: strconv.Itoa(i), Value: make([]byte, 50)})
+	}
+	if stats := c.Stats(); stats.Bytes > 1000 || stats.Bytes < 900 {
+		t.Errorf("got %d bytes, want between 900 and 1000", stats.Bytes)
+	}
+
+	err := c.Set(&MemData{Key: "huge", Value: make([]byte, 1000)})
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Set: got %v, want ErrTooLarge", err)
+	}
+}
+
+func TestShardsCleanExpired(t *testing.T) {
+	c := NewCache(WithShards(4))
+	for i := 0; i < 100; i++ {
+		expire := time.Now().Add(time.Hour).Unix()
+		if i%2 == 0 {
+			expire = time.Now().Add(-time.Hour).Unix()
+		}
+		c.Set(&MemData{Key: strconv.Itoa(i), Value: []byte("value"), Expire: expire})
+	}
+
+	c.CleanExpired()
+	for i := 0; i < 100; i++ {
+		_, ok := c.shardFor(strconv.Itoa(i)).data[strconv.Itoa(i)]
+		if ok == (i%2 == 0) {
+			t.Errorf("key %d: stored %v after CleanExpired", i, ok)
+		}
+	}
+}
+
+// benchmarkParallel runs a 90% read, 10% write workload on many goroutines
+func benchmarkParallel(b *testing.B, shardCount int) {
+	c := NewCache(WithShards(shardCount))
+	for i := 0; i < 10000; i++ {
+		c.Set(&MemData{Key: strconv.Itoa(i), Value: []byte("value")})
+	}
+
+	b.SetParallelism(64)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := strconv.Itoa(i % 10000)
+			if i%10 == 0 {
+				c.Replace(key, &MemData{Value: []byte("new value")})
+			} else {
+				c.Get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkParallel(b *testing.B) {
+	for _, n := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", n), func(b *testing.B) {
+			benchmarkParallel(b, n)
+		})
+	}
+}
+
+// BenchmarkGetWhileCleaning measures reads while CleanExpired keeps scanning the cache
+func BenchmarkGetWhileCleaning(b *testing.B) {
+	for _, n := range []int{1, 16} {
+		b.Run(fmt.Sprintf("shards=%d", n), func(b *testing.B) {
+			c := NewCache(WithShards(n))
+			for i := 0; i < 100000; i++ {
+				c.Set(&MemData{Key: strconv.Itoa(i), Value: []byte("value")})
+			}
+
+			done := make(chan bool)
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						c.CleanExpired()
+					}
+				}
+			}()
+			defer close(done)
+
+			b.SetParallelism(64)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					c.Get(strconv.Itoa(i % 100000))
+					i++
+				}
+			})
+		})
+	}
+}
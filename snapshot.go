@@ -0,0 +1,336 @@
+package mem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshot format options
+const (
+	SNAPSHOT_MAGIC   = "MEMS" // first bytes of every snapshot
+	SNAPSHOT_VERSION = 1      // current version of the snapshot format
+)
+
+// Snapshot record markers
+const (
+	snapshotEnd   = 0 // the trailer follows
+	snapshotEntry = 1 // an entry record follows
+)
+
+// maxSnapshotField is the largest key or value accepted from a snapshot
+const maxSnapshotField = 1 << 30
+
+// snapshotChunk is the largest field read with a single allocation, the larger
+// fields grow as their bytes are read so a corrupted length cannot allocate
+// maxSnapshotField up front
+const snapshotChunk = 64 << 10
+
+// SaveSnapshot writes all the live data of the cache to w in a versioned binary
+// format. Every entry record carries its own CRC-32 checksum and the snapshot
+// ends with the number of entries and a checksum of the whole stream.
+//
+// Layout, all integers are big endian:
+//
+//	header:  "MEMS" | version uint16
+//	entry:   0x01 | key length uint32 | key | value length uint32 | value |
+//	         Expire int64 | Created int64 | expiry nanos int64 |
+//	         sliding nanos int64 | max expiry nanos int64 | tag count uint32 |
+//	         (tag length uint32 | tag)... | crc32 of the entry uint32
+//	trailer: 0x00 | entry count uint64 | crc32 of all the preceding bytes uint32
+func (c *Cache) SaveSnapshot(w io.Writer) error {
+	sw := &snapshotWriter{w: bufio.NewWriter(w), sum: crc32.NewIEEE()}
+	sw.write([]byte(SNAPSHOT_MAGIC))
+	sw.writeUint16(SNAPSHOT_VERSION)
+
+	// Copy the live data of one shard at a time, so writing never holds a lock
+	var count uint64
+	for _, s := range c.shards {
+		now := c.now().UnixNano()
+		var entries []MemData
+		s.mu.RLock()
+		for _, data := range s.data {
+			if !data.isExpiredAt(now) {
+				entries = append(entries, *data)
+			}
+		}
+		s.mu.RUnlock()
+
+		for i := range entries {
+			sw.writeEntry(&entries[i])
+			count++
+		}
+	}
+
+	sw.writeByte(snapshotEnd)
+	sw.writeUint64(count)
+	sw.writeUint32(sw.sum.Sum32())
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot into the cache, replacing
+// the data with the same keys. Data that expired since the snapshot was taken is
+// skipped. Nothing is loaded if the snapshot is corrupted.
+func (c *Cache) LoadSnapshot(r io.Reader) error {
+	sr := &snapshotReader{r: bufio.NewReader(r), sum: crc32.NewIEEE()}
+	if magic := sr.read(len(SNAPSHOT_MAGIC)); sr.err == nil && string(magic) != SNAPSHOT_MAGIC {
+		return fmt.Errorf("%w: bad magic %q", ErrBadSnapshot, magic)
+	}
+	if version := sr.readUint16(); sr.err == nil && version != SNAPSHOT_VERSION {
+		return fmt.Errorf("%w: unsupported version %d", ErrBadSnapshot, version)
+	}
+
+	// Read and verify the whole snapshot before loading anything
+	var entries []*MemData
+	for sr.err == nil {
+		marker := sr.readByte()
+		if sr.err != nil || marker == snapshotEnd {
+			break
+		}
+		if marker != snapshotEntry {
+			return fmt.Errorf("%w: bad record marker %d", ErrBadSnapshot, marker)
+		}
+		if m := sr.readEntry(); m != nil {
+			entries = append(entries, m)
+		}
+	}
+
+	count := sr.readUint64()
+	sum := sr.sum.Sum32()
+	wantSum := sr.readUint32()
+	switch {
+	case errors.Is(sr.err, io.EOF) || errors.Is(sr.err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("%w: truncated", ErrBadSnapshot)
+	case sr.err != nil:
+		return sr.err
+	case count != uint64(len(entries)):
+		return fmt.Errorf("%w: %d entries, the trailer says %d", ErrBadSnapshot, len(entries), count)
+	case sum != wantSum:
+		return fmt.Errorf("%w: checksum mismatch", ErrBadSnapshot)
+	}
+
+	now := c.now().UnixNano()
+	for _, m := range entries {
+		if m.isExpiredAt(now) {
+			continue
+		}
+		if err := c.restore(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveSnapshotFile writes the snapshot of the cache to the file, the file is
+// replaced atomically so a crash never leaves a partial snapshot behind
+func (c *Cache) SaveSnapshotFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := c.SaveSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadSnapshotFile reads the snapshot file into the cache, a missing file is not an error
+func (c *Cache) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.LoadSnapshot(f)
+}
+
+// restore stores the data as it was saved, keeping its created timestamp
+func (c *Cache) restore(m *MemData) error {
+	var evicted []evictedData
+	defer c.notifyEvicted(&evicted)
+
+	s := c.shardFor(m.Key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return c.insertLocked(s, m, m.Created, &evicted)
+}
+
+// snapshotWriter writes the snapshot fields, keeping the first error
+type snapshotWriter struct {
+	w     *bufio.Writer
+	sum   hash.Hash32 // checksum of the whole stream
+	entry hash.Hash32 // checksum of the current entry, nil outside of an entry
+	err   error
+}
+
+func (sw *snapshotWriter) write(b []byte) {
+	if sw.err != nil {
+		return
+	}
+	_, sw.err = sw.w.Write(b)
+	sw.sum.Write(b)
+	if sw.entry != nil {
+		sw.entry.Write(b)
+	}
+}
+
+func (sw *snapshotWriter) writeByte(v byte) {
+	sw.write([]byte{v})
+}
+
+func (sw *snapshotWriter) writeUint16(v uint16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	sw.write(b[:])
+}
+
+func (sw *snapshotWriter) writeUint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	sw.write(b[:])
+}
+
+func (sw *snapshotWriter) writeUint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	sw.write(b[:])
+}
+
+// writeEntry writes the entry record with its checksum
+func (sw *snapshotWriter) writeEntry(m *MemData) {
+	sw.writeByte(snapshotEntry)
+	sw.entry = crc32.NewIEEE()
+	sw.writeUint32(uint32(len(m.Key)))
+	sw.write([]byte(m.Key))
+	sw.writeUint32(uint32(len(m.Value)))
+	sw.write(m.Value)
+	sw.writeUint64(uint64(m.Expire))
+	sw.writeUint64(uint64(m.Created))
+	sw.writeUint64(uint64(m.expireAt))
+	sw.writeUint64(uint64(m.sliding))
+	sw.writeUint64(uint64(m.maxExpireAt))
+	sw.writeUint32(uint32(len(m.Tags)))
+	for _, tag := range m.Tags {
+		sw.writeUint32(uint32(len(tag)))
+		sw.write([]byte(tag))
+	}
+	sum := sw.entry.Sum32()
+	sw.entry = nil
+	sw.writeUint32(sum)
+}
+
+// snapshotReader reads the snapshot fields, keeping the first error
+type snapshotReader struct {
+	r     *bufio.Reader
+	sum   hash.Hash32 // checksum of the whole stream
+	entry hash.Hash32 // checksum of the current entry, nil outside of an entry
+	err   error
+}
+
+func (sr *snapshotReader) read(n int) []byte {
+	if sr.err != nil {
+		return nil
+	}
+	if n < 0 || n > maxSnapshotField {
+		sr.err = fmt.Errorf("%w: field of %d bytes", ErrBadSnapshot, n)
+		return nil
+	}
+	var b []byte
+	if n <= snapshotChunk {
+		b = make([]byte, n)
+		if _, sr.err = io.ReadFull(sr.r, b); sr.err != nil {
+			return nil
+		}
+	} else {
+		var buf bytes.Buffer
+		if _, sr.err = io.CopyN(&buf, sr.r, int64(n)); sr.err != nil {
+			return nil
+		}
+		b = buf.Bytes()
+	}
+	sr.sum.Write(b)
+	if sr.entry != nil {
+		sr.entry.Write(b)
+	}
+	return b
+}
+
+func (sr *snapshotReader) readByte() byte {
+	if b := sr.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (sr *snapshotReader) readUint16() uint16 {
+	if b := sr.read(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (sr *snapshotReader) readUint32() uint32 {
+	if b := sr.read(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (sr *snapshotReader) readUint64() uint64 {
+	if b := sr.read(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// readEntry reads an entry record and verifies its checksum
+func (sr *snapshotReader) readEntry() *MemData {
+	sr.entry = crc32.NewIEEE()
+	m := &MemData{}
+	m.Key = string(sr.read(int(sr.readUint32())))
+	m.Value = sr.read(int(sr.readUint32()))
+	m.Expire = int64(sr.readUint64())
+	m.Created = int64(sr.readUint64())
+	m.expireAt = int64(sr.readUint64())
+	m.sliding = time.Duration(sr.readUint64())
+	m.maxExpireAt = int64(sr.readUint64())
+	n := sr.readUint32()
+	for i := uint32(0); i < n && sr.err == nil; i++ {
+		m.Tags = append(m.Tags, string(sr.read(int(sr.readUint32()))))
+	}
+	sum := sr.entry.Sum32()
+	sr.entry = nil
+
+	if wantSum := sr.readUint32(); sr.err == nil && sum != wantSum {
+		sr.err = fmt.Errorf("%w: checksum mismatch for key: %s", ErrBadSnapshot, m.Key)
+	}
+	if sr.err != nil {
+		return nil
+	}
+	return m
+}
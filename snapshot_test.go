@@ -0,0 +1,170 @@
+package mem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/itrepablik/mem/memtest"
+)
+
+// snapshotCache returns a cache with live, sliding and expired data
+func snapshotCache() *Cache {
+	c := NewCache(WithShards(4))
+	c.Set(&MemData{Key: "key1", Value: []byte("value1")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value2"), Expire: time.Now().Add(time.Hour).Unix()})
+	c.SetWithTTL("key3", []byte("value3"), time.Minute)
+	c.SetSliding("key4", []byte("value4"), time.Minute, time.Hour)
+	c.SetWithTTL("empty", nil, 0)
+	c.Set(&MemData{Key: "old", Value: []byte("old"), Expire: time.Now().Add(-time.Hour).Unix()})
+	return c
+}
+
+func TestSnapshot(t *testing.T) {
+	c := snapshotCache()
+
+	var buf bytes.Buffer
+	if err := c.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewCache()
+	if err := restored.LoadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if n := restored.Stats().Entries; n != 5 {
+		t.Errorf("restored %d entries, want 5", n)
+	}
+	if _, ok := restored.shards[0].data["old"]; ok {
+		t.Error("expired data was restored")
+	}
+
+	// Every field of the live data survives the round trip
+	for _, key := range []string{"key1", "key2", "key3", "key4", "empty"} {
+		want := *c.shardFor(key).data[key]
+		got := *restored.shards[0].data[key]
+		if got.Key != want.Key || !bytes.Equal(got.Value, want.Value) || got.Expire != want.Expire ||
+			got.Created != want.Created || got.expireAt != want.expireAt || got.sliding != want.sliding ||
+			got.maxExpireAt != want.maxExpireAt {
+			t.Errorf("%s: got %+v, want %+v", key, got, want)
+		}
+	}
+}
+
+func TestSnapshotSkipsExpiredOnLoad(t *testing.T) {
+	clk := memtest.NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local))
+	c := NewCache(WithClock(clk))
+	c.SetWithTTL("key", []byte("value"), 50*time.Millisecond)
+	c.Set(&MemData{Key: "forever", Value: []byte("value")})
+
+	var buf bytes.Buffer
+	if err := c.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(100 * time.Millisecond)
+
+	restored := NewCache(WithClock(clk))
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restored.shards[0].data["key"]; ok {
+		t.Error("data expired since the snapshot was restored")
+	}
+	if _, ok := restored.Get("forever"); !ok {
+		t.Error("live data was not restored")
+	}
+}
+
+func TestSnapshotCorrupted(t *testing.T) {
+	var buf bytes.Buffer
+	if err := snapshotCache().SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	tests := map[string][]byte{
+		"flipped byte": func() []byte {
+			b := append([]byte(nil), snapshot...)
+			b[len(b)/2] ^= 0xff
+			return b
+		}(),
+		"truncated":   snapshot[:len(snapshot)-3],
+		"bad magic":   append([]byte("NOPE"), snapshot[4:]...),
+		"bad version": append(append([]byte(SNAPSHOT_MAGIC), 0, 99), snapshot[6:]...),
+		"empty":       {},
+	}
+	for name, b := range tests {
+		c := NewCache()
+		err := c.LoadSnapshot(bytes.NewReader(b))
+		if !errors.Is(err, ErrBadSnapshot) {
+			t.Errorf("%s: got %v, want ErrBadSnapshot", name, err)
+		}
+		if n := c.Stats().Entries; n != 0 {
+			t.Errorf("%s: %d entries loaded from a corrupted snapshot", name, n)
+		}
+	}
+}
+
+func TestSnapshotCorruptedLength(t *testing.T) {
+	var buf bytes.Buffer
+	if err := snapshotCache().SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key length of the first entry, after the header and the record marker
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b[7:], maxSnapshotField)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := NewCache().LoadSnapshot(bytes.NewReader(b))
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrBadSnapshot) {
+		t.Errorf("got %v, want ErrBadSnapshot", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 16<<20 {
+		t.Errorf("allocated %d bytes for a corrupted length", n)
+	}
+}
+
+func TestSnapshotLargeValue(t *testing.T) {
+	value := bytes.Repeat([]byte("0123456789"), snapshotChunk)
+	c := NewCache()
+	c.Set(&MemData{Key: "large", Value: value})
+
+	var buf bytes.Buffer
+	if err := c.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewCache()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := restored.Get("large"); !ok || !bytes.Equal(v, value) {
+		t.Errorf("the large value was not restored, got %d bytes", len(v))
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snap")
+
+	// A missing snapshot file is a cold start, not an error
+	if err := NewCache().LoadSnapshotFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snapshotCache().SaveSnapshotFile(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewCache()
+	if err := restored.LoadSnapshotFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := restored.Get("key1"); !ok || string(v) != "value1" {
+		t.Errorf("Get: got %q, %v", v, ok)
+	}
+}
@@ -0,0 +1,62 @@
+package mem
+
+import "sync/atomic"
+
+// CacheStats is a point in time snapshot of the cache statistics
+type CacheStats struct {
+	Hits        uint64 // Get calls that found live data
+	Misses      uint64 // Get calls that found no data or expired data
+	Sets        uint64 // data stored by Set and the other set methods, including new counters
+	Replaces    uint64 // data replaced by Replace, including counter updates
+	Deletes     uint64 // data removed by Delete, DeleteMatching and InvalidateTag
+	Expirations uint64 // expired data removed by CleanExpired
+	Evictions   uint64 // data evicted to stay within the cache limits
+	Entries     int    // number of entries currently stored, including expired ones not yet cleaned
+	Bytes       int64  // total size of the keys and values currently stored
+}
+
+// HitRatio returns the ratio of the Get calls that found live data, from 0 to 1
+func (s CacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// cacheCounters are the atomic counters behind the cache statistics, all 64-bit,
+// keep them at the start of Cache so they stay aligned on 32-bit platforms
+type cacheCounters struct {
+	hits        uint64
+	misses      uint64
+	sets        uint64
+	replaces    uint64
+	deletes     uint64
+	expirations uint64
+	evictions   uint64
+}
+
+// Stats returns the statistics of the cache
+func (c *Cache) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:        atomic.LoadUint64(&c.counters.hits),
+		Misses:      atomic.LoadUint64(&c.counters.misses),
+		Sets:        atomic.LoadUint64(&c.counters.sets),
+		Replaces:    atomic.LoadUint64(&c.counters.replaces),
+		Deletes:     atomic.LoadUint64(&c.counters.deletes),
+		Expirations: atomic.LoadUint64(&c.counters.expirations),
+		Evictions:   atomic.LoadUint64(&c.counters.evictions),
+	}
+
+	for _, s := range c.shards {
+		s.mu.RLock()
+		stats.Entries += len(s.data)
+		stats.Bytes += s.size
+		s.mu.RUnlock()
+	}
+	return stats
+}
+
+// Evictions returns the number of entries evicted to stay within the cache limits
+func (c *Cache) Evictions() uint64 {
+	return atomic.LoadUint64(&c.counters.evictions)
+}
@@ -0,0 +1,56 @@
+package mem
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	c := NewCache(WithShards(4), WithMaxEntries(8))
+
+	c.Set(&MemData{Key: "key1", Value: []byte("value1")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value2")})
+	c.Set(&MemData{Key: "key1", Value: []byte("value1")}) // key exists, not counted
+	c.SetWithTTL("old", []byte("value"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	c.Get("key1")
+	c.Get("key2")
+	c.Get("old")
+	c.Get("missing")
+	c.Replace("key1", &MemData{Value: []byte("new value1")})
+	c.Replace("missing", &MemData{Value: []byte("value")}) // not found, not counted
+	c.Delete("key2")
+	c.Delete("missing") // not found, not counted
+	c.CleanExpired()
+
+	stats := c.Stats()
+	want := CacheStats{
+		Hits:        2,
+		Misses:      2,
+		Sets:        3,
+		Replaces:    1,
+		Deletes:     1,
+		Expirations: 1,
+		Entries:     1,
+		Bytes:       int64(len("key1") + len("new value1")),
+	}
+	if stats != want {
+		t.Errorf("Stats:\ngot  %+v\nwant %+v", stats, want)
+	}
+	if stats.HitRatio() != 0.5 {
+		t.Errorf("HitRatio: got %f, want 0.5", stats.HitRatio())
+	}
+}
+
+func TestStatsEvictions(t *testing.T) {
+	c := NewCache(WithMaxEntries(10))
+	for i := 0; i < 25; i++ {
+		c.Set(&MemData{Key: string(rune('a' + i)), Value: []byte("value")})
+	}
+
+	stats := c.Stats()
+	if stats.Evictions != 15 || stats.Entries != 10 || stats.Sets != 25 {
+		t.Errorf("Stats: %+v", stats)
+	}
+}
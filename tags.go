@@ -0,0 +1,45 @@
+package mem
+
+import "sync/atomic"
+
+// InvalidateTag deletes all the data carrying the tag, one shard at a time, it
+// returns the number of data deleted
+func (c *Cache) InvalidateTag(tag string) int {
+	removed := 0
+	for _, s := range c.shards {
+		var evicted []evictedData
+		s.mu.Lock()
+		for key := range s.tags[tag] {
+			if data, ok := s.remove(key); ok {
+				c.logDelete(key)
+				removed++
+				c.addEvicted(&evicted, data, EVICT_DELETED)
+			}
+		}
+		s.mu.Unlock()
+		c.notifyEvicted(&evicted)
+	}
+	atomic.AddUint64(&c.counters.deletes, uint64(removed))
+	return removed
+}
+
+// InvalidateTag deletes all the data carrying the tag from the default cache
+func InvalidateTag(tag string) int {
+	return defaultCache.InvalidateTag(tag)
+}
+
+// uniqueTags returns a copy of the tags without duplicates, nil if there are none
+func uniqueTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	unique := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		if _, ok := seen[tag]; !ok {
+			seen[tag] = struct{}{}
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,135 @@
+package mem
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache(WithShards(4))
+	for i := 0; i < 10; i++ {
+		c.Set(&MemData{Key: "user:1:" + string(rune('a'+i)), Value: []byte("value"), Tags: []string{"user:1", "page"}})
+		c.Set(&MemData{Key: "user:2:" + string(rune('a'+i)), Value: []byte("value"), Tags: []string{"user:2"}})
+	}
+
+	var deleted int
+	c.OnEvict(func(key string, value []byte, reason EvictReason) {
+		if reason == EVICT_DELETED {
+			deleted++
+		}
+	})
+	if n := c.InvalidateTag("user:1"); n != 10 {
+		t.Errorf("InvalidateTag removed %d, want 10", n)
+	}
+	if deleted != 10 {
+		t.Errorf("OnEvict got %d deletes, want 10", deleted)
+	}
+	if n := c.Stats().Entries; n != 10 {
+		t.Errorf("got %d entries, want 10", n)
+	}
+	if n := c.InvalidateTag("page"); n != 0 {
+		t.Errorf("InvalidateTag of the other tag of the removed data removed %d", n)
+	}
+	if n := c.InvalidateTag("missing"); n != 0 {
+		t.Errorf("InvalidateTag of a missing tag removed %d", n)
+	}
+	for _, s := range c.shards {
+		if _, ok := s.tags["user:1"]; ok {
+			t.Error("the index still has the invalidated tag")
+		}
+	}
+}
+
+func TestTagIndex(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache()
+	s := c.shards[0]
+	c.Set(&MemData{Key: "deleted", Value: []byte("value"), Tags: []string{"tag"}})
+	c.Set(&MemData{Key: "replaced", Value: []byte("value"), Tags: []string{"tag", "tag"}})
+	c.Set(&MemData{Key: "expired", Value: []byte("value"), Tags: []string{"tag"}, Expire: time.Now().Add(-time.Hour).Unix()})
+	if n := len(s.tags["tag"]); n != 3 {
+		t.Fatalf("got %d keys for the tag, want 3", n)
+	}
+
+	c.Delete("deleted")
+	if _, ok := s.tags["tag"]["deleted"]; ok {
+		t.Error("Delete left the key in the index")
+	}
+
+	// Replace replaces the tags as well
+	c.Replace("replaced", &MemData{Value: []byte("new value"), Tags: []string{"other"}})
+	if _, ok := s.tags["tag"]["replaced"]; ok {
+		t.Error("Replace left the key under the old tag")
+	}
+	if _, ok := s.tags["other"]["replaced"]; !ok {
+		t.Error("Replace did not index the new tag")
+	}
+
+	c.CleanExpired()
+	if _, ok := s.tags["tag"]; ok {
+		t.Error("CleanExpired left the key in the index")
+	}
+
+	c.ClearAll()
+	if len(s.tags) != 0 {
+		t.Errorf("ClearAll left %d tags in the index", len(s.tags))
+	}
+}
+
+func TestTagsPersisted(t *testing.T) {
+	c := NewCache()
+	c.Set(&MemData{Key: "key", Value: []byte("value"), Tags: []string{"a", "b"}})
+
+	var buf bytes.Buffer
+	if err := c.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewCache()
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := restored.InvalidateTag("b"); n != 1 {
+		t.Errorf("InvalidateTag of the restored data removed %d, want 1", n)
+	}
+
+	path := filepath.Join(t.TempDir(), "cache.log")
+	c, l := openLogCache(t, path, FSYNC_NEVER)
+	c.Set(&MemData{Key: "key", Value: []byte("value"), Tags: []string{"a"}})
+	l.Close()
+	c, l = openLogCache(t, path, FSYNC_NEVER)
+	defer l.Close()
+	if n := c.InvalidateTag("a"); n != 1 {
+		t.Errorf("InvalidateTag of the replayed data removed %d, want 1", n)
+	}
+}
+
+func TestCompareAndSwapKeepsTags(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache()
+	version, _ := c.SetWithVersion(&MemData{Key: "key", Value: []byte("value"), Tags: []string{"u"}})
+	if _, err := c.CompareAndSwap("key", version, []byte("new value")); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.InvalidateTag("u"); n != 1 {
+		t.Errorf("InvalidateTag after CompareAndSwap removed %d, want 1", n)
+	}
+}
+
+func TestIncrKeepsTags(t *testing.T) {
+	t.Parallel()
+
+	c := NewCache()
+	c.Set(&MemData{Key: "count", Value: []byte("1"), Tags: []string{"u"}})
+	if _, err := c.Incr("count", 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.InvalidateTag("u"); n != 1 {
+		t.Errorf("InvalidateTag after Incr removed %d, want 1", n)
+	}
+}
@@ -0,0 +1,186 @@
+package mem
+
+import "hash/fnv"
+
+// cmSketch is a count-min sketch that estimates how often keys were used, the
+// counters are halved periodically so old popularity fades away
+type cmSketch struct {
+	rows      [4][]uint8 // counters per hash function, capped at 15
+	mask      uint64     // width of the rows minus one, the width is a power of two
+	additions int        // increments since the last reset
+	resetAt   int        // number of increments that triggers the reset
+}
+
+// newCMSketch returns a count-min sketch at least width counters wide
+func newCMSketch(width int) *cmSketch {
+	w := 16
+	for w < width {
+		w <<= 1
+	}
+
+	s := &cmSketch{mask: uint64(w - 1), resetAt: 10 * w}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+	}
+	return s
+}
+
+// indexes returns the counter index of the key in each row
+func (s *cmSketch) indexes(key string) [4]uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+
+	// Derive the row hashes from the two halves of the 64 bit hash
+	h1, h2 := sum, sum>>32|1
+	var idx [4]uint64
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) & s.mask
+	}
+	return idx
+}
+
+// increment records one use of the key
+func (s *cmSketch) increment(key string) {
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < 15 {
+			s.rows[i][j]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+// estimate returns the estimated number of uses of the key
+func (s *cmSketch) estimate(key string) uint8 {
+	est := uint8(15)
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < est {
+			est = s.rows[i][j]
+		}
+	}
+	return est
+}
+
+// reset halves all the counters
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// tinyLFUPolicy is the Window TinyLFU policy. New keys enter a small LRU window,
+// keys leaving the window enter the main segmented LRU (probation and protected)
+// and when the cache is full a key from the window only replaces the main victim
+// if the frequency sketch says it is used more often.
+type tinyLFUPolicy struct {
+	capacity  int       // target number of entries, 0 follows the number of stored entries
+	sketch    *cmSketch // frequency estimates of the keys
+	window    *keyList  // admission window for new keys, about 1% of the entries
+	probation *keyList  // main keys used once since entering the main segment
+	protected *keyList  // main keys used again, about 80% of the main segment
+}
+
+// NewTinyLFUPolicy returns a Window TinyLFU eviction policy
+func NewTinyLFUPolicy(capacity int) EvictionPolicy {
+	width := capacity
+	if width <= 0 {
+		width = 1 << 16
+	}
+	return &tinyLFUPolicy{
+		capacity:  capacity,
+		sketch:    newCMSketch(width),
+		window:    newKeyList(),
+		probation: newKeyList(),
+		protected: newKeyList(),
+	}
+}
+
+// size returns the capacity used to size the window and the protected segment
+func (p *tinyLFUPolicy) size() int {
+	if p.capacity > 0 {
+		return p.capacity
+	}
+	return p.window.len() + p.probation.len() + p.protected.len()
+}
+
+// windowMax returns the maximum number of keys in the window
+func (p *tinyLFUPolicy) windowMax() int {
+	return maxInt(1, p.size()/100)
+}
+
+// protectedMax returns the maximum number of keys in the protected segment
+func (p *tinyLFUPolicy) protectedMax() int {
+	return maxInt(1, (p.size()-p.windowMax())*8/10)
+}
+
+// OnInsert stores a new key in the window, moving the oldest window key to probation
+func (p *tinyLFUPolicy) OnInsert(key string) {
+	if p.window.has(key) || p.probation.has(key) || p.protected.has(key) {
+		p.OnAccess(key)
+		return
+	}
+
+	p.sketch.increment(key)
+	p.window.pushFront(key)
+	for p.window.len() > p.windowMax() {
+		p.probation.pushFront(p.window.removeBack())
+	}
+}
+
+// OnAccess records the use of the key and promotes probation keys to protected
+func (p *tinyLFUPolicy) OnAccess(key string) {
+	p.sketch.increment(key)
+
+	switch {
+	case p.window.has(key):
+		p.window.moveToFront(key)
+
+	case p.probation.has(key):
+		p.probation.remove(key)
+		p.protected.pushFront(key)
+		for p.protected.len() > p.protectedMax() {
+			p.probation.pushFront(p.protected.removeBack())
+		}
+
+	case p.protected.has(key):
+		p.protected.moveToFront(key)
+	}
+}
+
+// OnRemove forgets the key, its frequency stays in the sketch
+func (p *tinyLFUPolicy) OnRemove(key string) {
+	p.window.remove(key)
+	p.probation.remove(key)
+	p.protected.remove(key)
+}
+
+// Victim returns the main victim or the window candidate, whichever is used less
+func (p *tinyLFUPolicy) Victim() (string, bool) {
+	victim, ok := p.probation.back()
+	if !ok {
+		victim, ok = p.protected.back()
+	}
+	candidate, hasCandidate := p.window.back()
+
+	switch {
+	case !ok:
+		return candidate, hasCandidate
+
+	case hasCandidate && p.window.len() >= p.windowMax():
+		// The window is full, so its oldest key competes with the main victim
+		if p.sketch.estimate(candidate) > p.sketch.estimate(victim) {
+			p.window.remove(candidate)
+			p.probation.pushFront(candidate)
+			return victim, true
+		}
+		return candidate, true
+	}
+	return victim, true
+}
@@ -0,0 +1,118 @@
+package mem
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Cleanable is a cache that the cleaner can remove expired data from
+type Cleanable interface {
+	CleanExpired() int // returns the number of expired data removed
+}
+
+// TypedData is a struct that holds the typed data for the memory
+type TypedData[K comparable, V any] struct {
+	Key     K     // key for the data
+	Value   V     // data to be stored in memory
+	Expire  int64 // unix timestamp, 0 means never expire
+	Created int64 // unix timestamp, the time the data stored in memory
+}
+
+// IsExpired returns true if the data is expired by the real time
+func (m *TypedData[K, V]) IsExpired() bool {
+	return isExpired(m.Expire)
+}
+
+// TypedCache is a cache that stores values of type V by keys of type K
+// without serializing them, e.g. structs, pointers or numeric keys. Its expiry
+// is always based on the real time, WithClock only applies to Cache.
+type TypedCache[K comparable, V any] struct {
+	data map[K]*TypedData[K, V] // map of the data
+	mu   *sync.RWMutex          // read-write mutex, multiple readers, single writer
+}
+
+// NewTypedCache returns a new typed cache
+func NewTypedCache[K comparable, V any]() *TypedCache[K, V] {
+	return &TypedCache[K, V]{
+		data: make(map[K]*TypedData[K, V]),
+		mu:   &sync.RWMutex{},
+	}
+}
+
+// Set sets the data in the cache
+func (c *TypedCache[K, V]) Set(m *TypedData[K, V]) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// If key already exists, return error
+	if _, ok := c.data[m.Key]; ok {
+		return fmt.Errorf("%w: %v", ErrKeyExists, m.Key)
+	}
+
+	c.data[m.Key] = &TypedData[K, V]{
+		Key:     m.Key,
+		Value:   m.Value,
+		Expire:  m.Expire,
+		Created: time.Now().Local().Unix(),
+	}
+	return nil
+}
+
+// Get gets the data from the cache, the zero value of V is returned when not found
+func (c *TypedCache[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if data, ok := c.data[key]; ok && !data.IsExpired() {
+		return data.Value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Replace replaces the data in the cache with the new data by the key
+func (c *TypedCache[K, V]) Replace(key K, m *TypedData[K, V]) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, ok := c.data[key]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrKeyNotFound, key)
+	}
+	if data.IsExpired() {
+		return fmt.Errorf("%w: %v", ErrExpired, key)
+	}
+	data.Value = m.Value
+	data.Expire = m.Expire
+	return nil
+}
+
+// Delete deletes the data from the cache
+func (c *TypedCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+}
+
+// ClearAll clears the cache
+func (c *TypedCache[K, V]) ClearAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data = make(map[K]*TypedData[K, V])
+}
+
+// CleanExpired cleans the expired cached data, it returns the number of data removed
+func (c *TypedCache[K, V]) CleanExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for k, v := range c.data {
+		if v.IsExpired() {
+			delete(c.data, k)
+			removed++
+		}
+	}
+	return removed
+}
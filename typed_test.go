@@ -0,0 +1,67 @@
+package mem
+
+import (
+	"testing"
+	"time"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func TestTypedCache(t *testing.T) {
+	c := NewTypedCache[int, *user]()
+
+	// Set the typed data in the cache
+	u := &user{Name: "juan", Age: 30}
+	err := c.Set(&TypedData[int, *user]{Key: 1, Value: u, Expire: time.Now().Add(time.Second * 3).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(&TypedData[int, *user]{Key: 1, Value: u}); err == nil {
+		t.Error("Set of an existing key should fail")
+	}
+
+	// Get returns the same pointer without any serialization
+	v, ok := c.Get(1)
+	if !ok || v != u {
+		t.Errorf("Get failed: %v, %v", v, ok)
+	}
+
+	// Replace the data in the cache
+	err = c.Replace(1, &TypedData[int, *user]{Value: &user{Name: "maria", Age: 25}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := c.Get(1); !ok || v.Name != "maria" {
+		t.Errorf("Replace failed: %v, %v", v, ok)
+	}
+
+	// Delete the data from the cache
+	c.Delete(1)
+	if v, ok := c.Get(1); ok || v != nil {
+		t.Errorf("Delete failed: %v, %v", v, ok)
+	}
+}
+
+func TestTypedCacheCleanExpired(t *testing.T) {
+	c := NewTypedCache[string, user]()
+	c.Set(&TypedData[string, user]{Key: "old", Value: user{Name: "old"}, Expire: time.Now().Add(-time.Hour).Unix()})
+	c.Set(&TypedData[string, user]{Key: "new", Value: user{Name: "new"}})
+
+	// Expired data is not returned even before it is cleaned
+	if _, ok := c.Get("old"); ok {
+		t.Error("Get returned expired data")
+	}
+
+	// The typed cache can be used with the cleaner through the Cleanable interface
+	var h Cleanable = c
+	h.CleanExpired()
+	if _, ok := c.data["old"]; ok {
+		t.Error("expired data was not cleaned")
+	}
+	if _, ok := c.Get("new"); !ok {
+		t.Error("live data was cleaned")
+	}
+}
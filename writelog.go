@@ -0,0 +1,411 @@
+package mem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy is how often the write log is flushed to the disk
+type FsyncPolicy int
+
+// Fsync policies, avoid 0 as it is the default
+const (
+	FSYNC_ALWAYS       FsyncPolicy = iota + 1 // fsync after every write, the safest and slowest
+	FSYNC_EVERY_SECOND                        // fsync once a second, up to a second of writes can be lost
+	FSYNC_NEVER                               // leave it to the operating system
+)
+
+// Write log record operations, the set record is a snapshot entry record
+const (
+	logSet    = snapshotEntry
+	logDelete = 2
+	logClear  = 3
+)
+
+// WriteLog is an append-only log of every Set, Replace, Delete and ClearAll
+// of a cache. It is replayed when the cache is created with WithWriteLog and
+// compacted into a snapshot by CompactLog. A write log must only be used by
+// one cache.
+//
+// Next to the log file at path, the log uses path.snap for the compacted
+// snapshot and path.old for the log being compacted.
+type WriteLog struct {
+	path      string
+	policy    FsyncPolicy
+	f         *os.File      // active log file
+	mu        sync.Mutex    // guards f, dirty and err
+	compactMu sync.Mutex    // allows one compaction at a time
+	dirty     bool          // written since the last fsync
+	err       error         // first write error
+	staged    *Cache        // data replayed on open, moved into the cache by NewCache
+	stop      chan struct{} // stops the fsync loop
+	done      chan struct{} // closed when the fsync loop returned
+}
+
+// OpenWriteLog opens the write log at path, creating it if needed, and reads
+// the data to replay. Records torn by a crash at the end of the log are dropped.
+func OpenWriteLog(path string, policy FsyncPolicy) (*WriteLog, error) {
+	switch policy {
+	case FSYNC_ALWAYS, FSYNC_EVERY_SECOND, FSYNC_NEVER:
+	default:
+		return nil, fmt.Errorf("invalid fsync policy: %d", policy)
+	}
+
+	// Replay the compacted snapshot, then the log being compacted, then the active log
+	l := &WriteLog{path: path, policy: policy, staged: NewCache()}
+	if err := l.staged.LoadSnapshotFile(l.snapPath()); err != nil {
+		return nil, err
+	}
+	for _, p := range []string{l.oldPath(), path} {
+		if err := replayLog(l.staged, p); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l.f = f
+
+	if policy == FSYNC_EVERY_SECOND {
+		l.stop, l.done = make(chan struct{}), make(chan struct{})
+		go l.syncLoop()
+	}
+	return l, nil
+}
+
+// WithWriteLog records every change of the cache in the write log and replays
+// the log into the new cache
+func WithWriteLog(l *WriteLog) CacheOption {
+	return func(c *Cache) {
+		c.wlog = l
+	}
+}
+
+// Err returns the first error that happened while writing or compacting the log
+func (l *WriteLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Close flushes the log to the disk and closes it. The cache must stop writing
+// before its log is closed, the changes made after Close are not recorded and
+// Err returns ErrLogClosed.
+func (l *WriteLog) Close() error {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+		l.stop = nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return l.err
+	}
+	err := l.f.Sync()
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+// CompactLog writes a snapshot of the cache and empties its write log. Writes
+// made during the compaction go to the new log, so none of them are lost.
+func (c *Cache) CompactLog() error {
+	l := c.wlog
+	if l == nil {
+		return nil
+	}
+	l.compactMu.Lock()
+	defer l.compactMu.Unlock()
+
+	if err := l.rotate(); err != nil {
+		return l.fail(err)
+	}
+	if err := c.SaveSnapshotFile(l.snapPath()); err != nil {
+		return l.fail(err)
+	}
+
+	// The snapshot covers the rotated log, so it is no longer needed
+	if err := os.Remove(l.oldPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return l.fail(err)
+	}
+	return nil
+}
+
+// CompactionJob returns a job that can be run by a Cleaner, every run cleans
+// the expired data and then compacts the write log of the cache
+func (c *Cache) CompactionJob() Cleanable {
+	return &compactionJob{c: c}
+}
+
+// compactionJob is the Cleanable returned by CompactionJob
+type compactionJob struct {
+	c *Cache
+}
+
+// CleanExpired cleans the expired data and compacts the write log
+func (j *compactionJob) CleanExpired() int {
+	removed := j.c.CleanExpired()
+	j.c.CompactLog() // the error is kept by the write log
+	return removed
+}
+
+// replay moves the data read on open into the cache, c.wlog is not attached yet
+func (l *WriteLog) replay(c *Cache) {
+	if l.staged == nil {
+		return
+	}
+
+	now := c.now().UnixNano()
+	for _, s := range l.staged.shards {
+		for _, data := range s.data {
+			if !data.isExpiredAt(now) {
+				c.restore(data)
+			}
+		}
+	}
+	l.staged = nil
+}
+
+// rotate moves the active log to path.old and starts a new empty log
+func (l *WriteLog) rotate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return ErrLogClosed
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+
+	// A previous compaction failed, keep its records before the active ones
+	if _, err := os.Stat(l.oldPath()); err == nil {
+		if err := appendFile(l.oldPath(), l.path); err != nil {
+			return err
+		}
+	} else if err := os.Rename(l.path, l.oldPath()); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	l.f.Close()
+	l.f = f
+	l.dirty = false
+	return nil
+}
+
+// append writes the record to the log according to the fsync policy
+func (l *WriteLog) append(record []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	if l.f == nil {
+		// The record is lost, make Err report it
+		l.err = ErrLogClosed
+		return
+	}
+
+	if _, err := l.f.Write(record); err != nil {
+		l.err = err
+		return
+	}
+	switch l.policy {
+	case FSYNC_ALWAYS:
+		if err := l.f.Sync(); err != nil {
+			l.err = err
+		}
+	case FSYNC_EVERY_SECOND:
+		l.dirty = true
+	}
+}
+
+// syncLoop flushes the log to the disk once a second
+func (l *WriteLog) syncLoop() {
+	defer close(l.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.f != nil && l.dirty {
+				if err := l.f.Sync(); err != nil && l.err == nil {
+					l.err = err
+				}
+				l.dirty = false
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// fail records the error and returns it
+func (l *WriteLog) fail(err error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = err
+	}
+	return err
+}
+
+// snapPath returns the path of the compacted snapshot
+func (l *WriteLog) snapPath() string {
+	return l.path + ".snap"
+}
+
+// oldPath returns the path of the log being compacted
+func (l *WriteLog) oldPath() string {
+	return l.path + ".old"
+}
+
+// logSet records the stored data, s.mu of the data must be held
+func (c *Cache) logSet(m *MemData) {
+	if c.wlog == nil {
+		return
+	}
+	var buf bytes.Buffer
+	sw := &snapshotWriter{w: bufio.NewWriter(&buf), sum: crc32.NewIEEE()}
+	sw.writeEntry(m)
+	sw.w.Flush()
+	c.wlog.append(encodeLogRecord(buf.Bytes()))
+}
+
+// logDelete records the deleted key, s.mu of the key must be held
+func (c *Cache) logDelete(key string) {
+	if c.wlog == nil {
+		return
+	}
+	payload := make([]byte, 5, 5+len(key))
+	payload[0] = logDelete
+	binary.BigEndian.PutUint32(payload[1:], uint32(len(key)))
+	c.wlog.append(encodeLogRecord(append(payload, key...)))
+}
+
+// logClear records that the cache was cleared
+func (c *Cache) logClear() {
+	if c.wlog == nil {
+		return
+	}
+	c.wlog.append(encodeLogRecord([]byte{logClear}))
+}
+
+// encodeLogRecord frames the payload as payload length uint32 | payload | crc32 of the payload uint32
+func encodeLogRecord(payload []byte) []byte {
+	record := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	copy(record[4:], payload)
+	binary.BigEndian.PutUint32(record[4+len(payload):], crc32.ChecksumIEEE(payload))
+	return record
+}
+
+// replayLog applies the records of the log file to the cache, a torn or
+// corrupted record ends the log and is truncated away with everything after it
+func replayLog(c *Cache, path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	off := 0
+	for off < len(b) {
+		payload, ok := decodeLogRecord(b[off:])
+		if !ok || !applyLogRecord(c, payload) {
+			return os.Truncate(path, int64(off))
+		}
+		off += 8 + len(payload)
+	}
+	return nil
+}
+
+// decodeLogRecord returns the payload of the record at the start of b, false if it is torn or corrupted
+func decodeLogRecord(b []byte) ([]byte, bool) {
+	if len(b) < 4 {
+		return nil, false
+	}
+	n := int(binary.BigEndian.Uint32(b))
+	if n > len(b)-8 || n < 0 {
+		return nil, false
+	}
+	payload := b[4 : 4+n]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(b[4+n:]) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// applyLogRecord applies the record payload to the cache, false if it is invalid
+func applyLogRecord(c *Cache, payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+
+	switch payload[0] {
+	case logSet:
+		sr := &snapshotReader{r: bufio.NewReader(bytes.NewReader(payload[1:])), sum: crc32.NewIEEE()}
+		m := sr.readEntry()
+		if m == nil {
+			return false
+		}
+		return c.restore(m) == nil
+
+	case logDelete:
+		if len(payload) < 5 || int(binary.BigEndian.Uint32(payload[1:])) != len(payload)-5 {
+			return false
+		}
+		c.Delete(string(payload[5:]))
+		return true
+
+	case logClear:
+		c.ClearAll()
+		return true
+	}
+	return false
+}
+
+// appendFile appends the content of the src file to the dst file
+func appendFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
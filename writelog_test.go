@@ -0,0 +1,216 @@
+package mem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openLogCache opens the write log and a cache that replays it
+func openLogCache(t *testing.T, path string, policy FsyncPolicy) (*Cache, *WriteLog) {
+	t.Helper()
+	l, err := OpenWriteLog(path, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCache(WithWriteLog(l)), l
+}
+
+func TestWriteLogReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+
+	c, l := openLogCache(t, path, FSYNC_ALWAYS)
+	c.Set(&MemData{Key: "key1", Value: []byte("value1")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value2")})
+	c.SetWithTTL("key3", []byte("value3"), time.Hour)
+	c.SetWithTTL("short", []byte("value"), 50*time.Millisecond)
+	c.Replace("key1", &MemData{Value: []byte("new value1")})
+	c.Delete("key2")
+	created := c.shards[0].data["key1"].Created
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// A new cache on the same log gets the same data back
+	c, l = openLogCache(t, path, FSYNC_ALWAYS)
+	defer l.Close()
+	if v, ok := c.Get("key1"); !ok || string(v) != "new value1" {
+		t.Errorf("key1: got %q, %v", v, ok)
+	}
+	if c.shards[0].data["key1"].Created != created {
+		t.Error("created timestamp was not replayed")
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Error("deleted key2 was replayed")
+	}
+	if v, ok := c.Get("key3"); !ok || string(v) != "value3" {
+		t.Errorf("key3: got %q, %v", v, ok)
+	}
+	if _, ok := c.shards[0].data["short"]; ok {
+		t.Error("expired data was replayed")
+	}
+
+	// ClearAll is replayed as well
+	c.ClearAll()
+	c.Set(&MemData{Key: "key4", Value: []byte("value4")})
+	l.Close()
+
+	c, l = openLogCache(t, path, FSYNC_NEVER)
+	defer l.Close()
+	if n := c.Stats().Entries; n != 1 {
+		t.Errorf("got %d entries after ClearAll, want 1", n)
+	}
+}
+
+func TestWriteLogConcurrentClearAll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+	l, err := OpenWriteLog(path, FSYNC_NEVER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCache(WithShards(4), WithWriteLog(l))
+
+	// The writes racing with ClearAll are replayed like they ended up in memory
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				c.Set(&MemData{Key: fmt.Sprintf("%d-%d", w, i), Value: []byte("value")})
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for cleared := false; !cleared; {
+		select {
+		case <-done:
+			cleared = true
+		default:
+			c.ClearAll()
+		}
+	}
+	want := c.Stats().Entries
+	l.Close()
+
+	l, err = OpenWriteLog(path, FSYNC_NEVER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	restored := NewCache(WithShards(4), WithWriteLog(l))
+	if got := restored.Stats().Entries; got != want {
+		t.Errorf("got %d entries after the replay, want %d", got, want)
+	}
+	c.Range(func(key string, value []byte) bool {
+		if _, ok := restored.Get(key); !ok {
+			t.Errorf("%s was not replayed", key)
+		}
+		return true
+	})
+}
+
+func TestWriteLogTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+
+	c, l := openLogCache(t, path, FSYNC_ALWAYS)
+	c.Set(&MemData{Key: "key1", Value: []byte("value1")})
+	c.Set(&MemData{Key: "key2", Value: []byte("value2")})
+	l.Close()
+
+	// Simulate a crash in the middle of writing the last record
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	c, l = openLogCache(t, path, FSYNC_ALWAYS)
+	if _, ok := c.Get("key1"); !ok {
+		t.Error("key1 was not replayed")
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Error("torn key2 was replayed")
+	}
+
+	// The torn record is dropped, so new records can be appended after it
+	c.Set(&MemData{Key: "key3", Value: []byte("value3")})
+	l.Close()
+
+	c, l = openLogCache(t, path, FSYNC_ALWAYS)
+	defer l.Close()
+	if _, ok := c.Get("key3"); !ok {
+		t.Error("key3 written after the torn record was not replayed")
+	}
+}
+
+func TestWriteLogCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+
+	c, l := openLogCache(t, path, FSYNC_EVERY_SECOND)
+	for i := 0; i < 100; i++ {
+		c.Delete("key")
+		c.Set(&MemData{Key: "key", Value: []byte("value")})
+	}
+	c.SetWithTTL("old", []byte("value"), time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	// The compaction job cleans the expired data and empties the log
+	if removed := c.CompactionJob().CleanExpired(); removed != 1 {
+		t.Errorf("compaction job removed %d, want 1", removed)
+	}
+	if err := l.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Size() != 0 {
+		t.Errorf("log has %d bytes after the compaction", info.Size())
+	}
+	if _, err := os.Stat(path + ".snap"); err != nil {
+		t.Errorf("snapshot was not written: %s", err)
+	}
+
+	// Writes after the compaction are replayed on top of the snapshot
+	c.Set(&MemData{Key: "after", Value: []byte("value")})
+	l.Close()
+
+	c, l = openLogCache(t, path, FSYNC_EVERY_SECOND)
+	defer l.Close()
+	for _, key := range []string{"key", "after"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s was not replayed", key)
+		}
+	}
+}
+
+func TestWriteLogWriteAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.log")
+
+	c, l := openLogCache(t, path, FSYNC_ALWAYS)
+	c.Set(&MemData{Key: "key1", Value: []byte("value1")})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Err(); err != nil {
+		t.Fatalf("Err after Close: %v", err)
+	}
+
+	// A write after Close is not recorded, and the log reports it
+	c.Set(&MemData{Key: "key2", Value: []byte("value2")})
+	if err := l.Err(); !errors.Is(err, ErrLogClosed) {
+		t.Errorf("Err after a write: got %v, want ErrLogClosed", err)
+	}
+}
+
+func TestOpenWriteLogInvalidPolicy(t *testing.T) {
+	if _, err := OpenWriteLog(filepath.Join(t.TempDir(), "cache.log"), 0); err == nil {
+		t.Error("OpenWriteLog accepted an invalid fsync policy")
+	}
+}